@@ -0,0 +1,137 @@
+package insidescraper
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"time"
+)
+
+// Checkpoint is the state of a scrape which is in progress. A scrape can be
+// resumed from it, without visiting the pages which were already finished.
+type Checkpoint struct {
+	StartURL string
+	// RootID is the ID of the section which the start page is for, if it's
+	// one branch of the site.
+	RootID string `json:",omitempty"`
+	Time   time.Time
+	// Site is the site as far as it was scraped, including what was found so
+	// far on the pages which weren't finished.
+	Site Site
+	// Pages holds what was found on each finished page, by URL.
+	Pages map[string][]pageItem
+	// Problems holds the problems which were found on each finished page, by URL.
+	Problems map[string][]Problem `json:",omitempty"`
+	// Queued are the pages which were requested, but not finished.
+	Queued []QueuedPage
+}
+
+// QueuedPage is a page which is waiting to be scraped.
+type QueuedPage struct {
+	URL string
+	// Section is the ID of the section the page is for.
+	Section string
+	// Parent is the ID of the section which linked to the page.
+	Parent string
+}
+
+// ReadCheckpoint loads a checkpoint from a file.
+func ReadCheckpoint(path string) (Checkpoint, error) {
+	var checkpoint Checkpoint
+
+	jsonText, err := ioutil.ReadFile(path)
+	if err != nil {
+		return checkpoint, err
+	}
+
+	err = json.Unmarshal(jsonText, &checkpoint)
+	return checkpoint, err
+}
+
+// WriteCheckpoint saves a checkpoint to a file. The file is replaced all at
+// once, so a crash while writing doesn't lose the last checkpoint.
+func WriteCheckpoint(path string, checkpoint Checkpoint) error {
+	tmpPath := path + ".tmp"
+
+	if err := WriteJSON(tmpPath, checkpoint); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+// checkpoint saves the state of the scrape, if it's time to.
+func (scraper *InsideScraper) checkpoint(force bool) {
+	if scraper.Options.CheckpointPath == "" {
+		return
+	}
+
+	scraper.checkpointLock.Lock()
+	defer scraper.checkpointLock.Unlock()
+
+	if !force && time.Since(scraper.lastCheckpoint) < scraper.Options.CheckpointInterval {
+		return
+	}
+
+	checkpoint := Checkpoint{
+		StartURL: scraper.startURL,
+		RootID:   scraper.rootID,
+		Time:     time.Now(),
+		Pages:    make(map[string][]pageItem, len(scraper.finished)),
+		Problems: make(map[string][]Problem),
+		Queued:   make([]QueuedPage, 0, len(scraper.queued)),
+	}
+
+	// Only finished pages are saved; the pages which are still being scraped
+	// are visited again when resuming. The site is built from everything which
+	// was found so far, including on those pages, because the pages which lead
+	// to the others are the last ones to finish.
+	scraper.lock.Lock()
+	pages := make(map[string][]pageItem, len(scraper.pages))
+	for url, items := range scraper.pages {
+		pages[url] = items
+	}
+	for url := range scraper.finished {
+		checkpoint.Pages[url] = scraper.pages[url]
+		if problems := scraper.problems[url]; len(problems) > 0 {
+			checkpoint.Problems[url] = problems
+		}
+	}
+	for _, page := range scraper.queued {
+		checkpoint.Queued = append(checkpoint.Queued, page)
+	}
+	scraper.lock.Unlock()
+
+	sort.Slice(checkpoint.Queued, func(i, j int) bool {
+		return checkpoint.Queued[i].URL < checkpoint.Queued[j].URL
+	})
+
+	// Problems will be reported when the whole site is built.
+	checkpoint.Site = buildSite(pages, scraper.startURL, scraper.rootID, nil)
+
+	if err := WriteCheckpoint(scraper.Options.CheckpointPath, checkpoint); err != nil {
+		scraper.report.add(Problem{
+			Kind:    CheckpointError,
+			URL:     scraper.Options.CheckpointPath,
+			Message: err.Error(),
+		})
+	}
+
+	scraper.lastCheckpoint = time.Now()
+}
+
+// removeCheckpoint deletes the checkpoint of a scrape which finished.
+func (scraper *InsideScraper) removeCheckpoint() {
+	if scraper.Options.CheckpointPath == "" {
+		return
+	}
+
+	if err := os.Remove(scraper.Options.CheckpointPath); err != nil && !os.IsNotExist(err) {
+		scraper.report.add(Problem{
+			Kind:    CheckpointError,
+			URL:     scraper.Options.CheckpointPath,
+			Message: err.Error(),
+		})
+	}
+}
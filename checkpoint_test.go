@@ -0,0 +1,184 @@
+package insidescraper
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// failingTransport fails every request for a URL which ends with Fail.
+// It counts the GET requests for pages which it lets through.
+type failingTransport struct {
+	Fail string
+	Gets int
+}
+
+func (transport *failingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if transport.Fail != "" && strings.HasSuffix(request.URL.Path, transport.Fail) {
+		return nil, errors.New("failing on purpose")
+	}
+
+	if request.Method == "GET" {
+		transport.Gets++
+	}
+
+	return http.DefaultTransport.RoundTrip(request)
+}
+
+// snapshotTransport reads the checkpoint when the first request for a URL
+// which ends with At is made, to see the checkpoint in the middle of a scrape.
+type snapshotTransport struct {
+	failingTransport
+	At             string
+	CheckpointPath string
+	Snapshot       *Checkpoint
+}
+
+func (transport *snapshotTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if transport.Snapshot == nil && strings.HasSuffix(request.URL.Path, transport.At) {
+		checkpoint, err := ReadCheckpoint(transport.CheckpointPath)
+		if err != nil {
+			return nil, err
+		}
+		transport.Snapshot = &checkpoint
+	}
+
+	return transport.failingTransport.RoundTrip(request)
+}
+
+func TestResumeFromCheckpoint(t *testing.T) {
+	server := newFakeSite()
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	options := fakeSiteOptions(server)
+	options.CheckpointPath = filepath.Join(dir, "checkpoint.json")
+	options.CheckpointInterval = time.Nanosecond
+
+	// The first scrape stops at the first problem: a HEAD of the sub section,
+	// which is linked from section A.
+	options.Transport = &failingTransport{Fail: "/section-a/sub"}
+	options.Strict = true
+	crashed := InsideScraper{Options: options}
+	if _, err := crashed.Scrape(); err == nil {
+		t.Fatal("Expected the strict scrape to stop")
+	}
+
+	checkpoint, err := ReadCheckpoint(options.CheckpointPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	isQueued := false
+	for _, page := range checkpoint.Queued {
+		isQueued = isQueued || page.URL == server.URL+"/section-a/sub"
+	}
+	if !isQueued {
+		t.Fatalf("Expected the sub section to be queued, got %+v", checkpoint.Queued)
+	}
+	if problems := checkpoint.Problems[server.URL+"/section-a"]; len(problems) != 1 || problems[0].Kind != FailedHead {
+		t.Fatalf("Expected the problem on section A to be saved, got %+v", checkpoint.Problems)
+	}
+
+	options.Strict = false
+	transport := &failingTransport{}
+	options.Transport = transport
+	options.Resume = true
+	resumed := InsideScraper{Options: options}
+	report, err := resumed.Scrape()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Count(FailedHead) != 1 {
+		t.Errorf("Expected the problem from before resuming to be reported, got %+v", report.Problems)
+	}
+
+	// The full scrape is checked in the middle, once section A is finished and
+	// the home page isn't: when section B's link is followed.
+	fullOptions := fakeSiteOptions(server)
+	fullOptions.CheckpointPath = filepath.Join(dir, "full.json")
+	fullOptions.CheckpointInterval = time.Nanosecond
+	snapshot := &snapshotTransport{At: "/b", CheckpointPath: fullOptions.CheckpointPath}
+	fullTransport := &snapshot.failingTransport
+	fullOptions.Transport = snapshot
+	full := InsideScraper{Options: fullOptions}
+	if _, err := full.Scrape(); err != nil {
+		t.Fatal(err)
+	}
+
+	if snapshot.Snapshot == nil {
+		t.Fatal("Expected a checkpoint in the middle of the scrape")
+	}
+	partial := snapshot.Snapshot.Site
+	if _, isFinished := snapshot.Snapshot.Pages[server.URL+"/"]; isFinished {
+		t.Errorf("Expected the home page not to be finished yet")
+	}
+	if len(partial.TopLevel) != 1 || partial.TopLevel[0].ID != server.URL+"/section-a" {
+		t.Errorf("Expected the partial site to have section A, got %+v", partial.TopLevel)
+	}
+	sectionA := partial.Sections[server.URL+"/section-a"]
+	if len(sectionA.Lessons) != 1 || len(sectionA.Sections) != 1 || len(partial.Lessons) != 2 {
+		t.Errorf("Expected the partial site to have section A and its sub section, got %+v", partial)
+	}
+
+	// Pages which were queued, or which were found from them, are visited.
+	if transport.Gets != fullTransport.Gets-len(checkpoint.Pages) {
+		t.Errorf("Expected only the %d unfinished pages to be visited, but %d pages were",
+			fullTransport.Gets-len(checkpoint.Pages), transport.Gets)
+	}
+
+	resumedJSON, _ := json.Marshal(resumed.Site)
+	fullJSON, _ := json.Marshal(full.Site)
+	if string(resumedJSON) != string(fullJSON) {
+		t.Errorf("Resumed scrape:\n%s\ndoesn't match full scrape:\n%s", resumedJSON, fullJSON)
+	}
+}
+
+func TestResumeAfterFinishedScrape(t *testing.T) {
+	server := newFakeSite()
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	options := fakeSiteOptions(server)
+	options.CheckpointPath = filepath.Join(dir, "checkpoint.json")
+	options.CheckpointInterval = time.Nanosecond
+
+	first := &failingTransport{}
+	options.Transport = first
+	if _, err := (&InsideScraper{Options: options}).Scrape(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(options.CheckpointPath); !os.IsNotExist(err) {
+		t.Errorf("Expected the checkpoint of a finished scrape to be removed, got %v", err)
+	}
+
+	second := &failingTransport{}
+	options.Transport = second
+	options.Resume = true
+	resumed := InsideScraper{Options: options}
+	if _, err := resumed.Scrape(); err != nil {
+		t.Fatal(err)
+	}
+
+	if second.Gets != first.Gets || len(resumed.Site.Sections) == 0 {
+		t.Errorf("Expected a fresh scrape of %d pages, but %d were visited", first.Gets, second.Gets)
+	}
+}
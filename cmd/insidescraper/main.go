@@ -0,0 +1,603 @@
+// Command insidescraper runs the scrape → fix → count → resolve pipeline,
+// reading and writing site data as JSON files.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	insidescraper "github.com/yringler/inside-chassidus-scraper"
+)
+
+// command is one step (or chain of steps) of the pipeline.
+type command struct {
+	description string
+	run         func(args []string) error
+}
+
+var commands = map[string]command{
+	"scrape":    {"scrape the site and write the raw site data", runScrape},
+	"fix":       {"apply corrections to scraped site data", runFix},
+	"enrich":    {"add the size, type and duration of each media file", runEnrich},
+	"diff":      {"list what changed between two snapshots of the site data", runDiff},
+	"dedup":     {"merge lessons with the same audio, and report near duplicates", runDedup},
+	"count":     {"count the audio classes in each section", runCount},
+	"resolve":   {"resolve site data into its optimized form", runResolve},
+	"graph":     {"report cycles, shared sections and the depth of the section hierarchy", runGraph},
+	"validate":  {"check site data for broken references and empty items", runValidate},
+	"migrate":   {"upgrade site data to the current format version", runMigrate},
+	"merge":     {"merge a partial scrape into the site data", runMerge},
+	"all":       {"scrape, fix, count and resolve in one go", runAll},
+	"wordpress": {"load the site data from the WordPress REST API", runWordPress},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, exists := commands[os.Args[1]]
+	if !exists {
+		fmt.Fprintln(os.Stderr, "Unknown command: "+os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+
+	if err := cmd.run(os.Args[2:]); err != nil {
+		fmt.Fprintln(os.Stderr, "Error: "+err.Error())
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: insidescraper <command> [flags]\n\nCommands:")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, commands[name].description)
+	}
+}
+
+func runScrape(args []string) error {
+	flags := flag.NewFlagSet("scrape", flag.ExitOnError)
+	options := scraperFlags(flags)
+	out := flags.String("out", "scraped.json", "where to write the scraped site data")
+	report := flags.String("report", "", "where to write the report of problems found while scraping")
+	flags.Parse(args)
+
+	scraperOptions, err := options()
+	if err != nil {
+		return err
+	}
+
+	file, err := scrape(scraperOptions, *report)
+	if err != nil {
+		return err
+	}
+
+	return insidescraper.WriteSiteFile(*out, file)
+}
+
+func runWordPress(args []string) error {
+	flags := flag.NewFlagSet("wordpress", flag.ExitOnError)
+	url := flags.String("url", insidescraper.DefaultScraperOptions().BaseURL, "the root of the WordPress site")
+	out := flags.String("out", "scraped.json", "where to write the site data")
+	sortAudio := flags.Bool("sort-audio", false, "sort the classes of each lesson by the numbers in their titles")
+	transport := transportFlags(flags)
+	flags.Parse(args)
+
+	scraper := insidescraper.WordPressScraper{
+		BaseURL:   *url,
+		Transport: transport(),
+		SortAudio: *sortAudio,
+	}
+
+	start := time.Now()
+	if err := scraper.Scrape(); err != nil {
+		return err
+	}
+	end := time.Now()
+
+	return insidescraper.WriteSiteFile(*out, insidescraper.SiteFile{
+		SourceURL: *url,
+		StartTime: &start,
+		EndTime:   &end,
+		Site:      scraper.Site,
+	})
+}
+
+func runFix(args []string) error {
+	flags := flag.NewFlagSet("fix", flag.ExitOnError)
+	in := flags.String("in", "scraped.json", "the site data to fix")
+	out := flags.String("out", "fixed.json", "where to write the fixed site data")
+	transport := transportFlags(flags)
+	profile := profileFlag(flags)
+	flags.Parse(args)
+
+	file, err := insidescraper.ReadSiteFile(*in)
+	if err != nil {
+		return err
+	}
+
+	siteProfile, err := profile()
+	if err != nil {
+		return err
+	}
+
+	file.Site = fix(file.Site, transport(), siteProfile)
+	return insidescraper.WriteSiteFile(*out, file)
+}
+
+func runEnrich(args []string) error {
+	flags := flag.NewFlagSet("enrich", flag.ExitOnError)
+	in := flags.String("in", "fixed.json", "the site data to enrich")
+	out := flags.String("out", "enriched.json", "where to write the enriched site data")
+	parallelism := flags.Int("parallel", 1, "how many files to check at once")
+	prefix := flags.Int64("prefix", 16*1024, "how many bytes at the start of each MP3 to read, to find its duration")
+	report := flags.String("report", "", "where to write the media which couldn't be checked")
+	transport := transportFlags(flags)
+	flags.Parse(args)
+
+	file, err := insidescraper.ReadSiteFile(*in)
+	if err != nil {
+		return err
+	}
+
+	enricher := insidescraper.MediaEnricher{
+		Transport:   transport(),
+		PrefixSize:  *prefix,
+		Parallelism: *parallelism,
+	}
+	problems := enricher.Enrich(&file.Site)
+
+	fmt.Fprintf(os.Stderr, "Enriched media, with %d problems\n", len(problems))
+
+	if *report != "" {
+		if err := insidescraper.WriteJSON(*report, &insidescraper.ScrapeReport{Problems: problems}); err != nil {
+			return err
+		}
+	}
+
+	return insidescraper.WriteSiteFile(*out, file)
+}
+
+func runDedup(args []string) error {
+	flags := flag.NewFlagSet("dedup", flag.ExitOnError)
+	in := flags.String("in", "fixed.json", "the site data to deduplicate")
+	out := flags.String("out", "deduped.json", "where to write the deduplicated site data")
+	report := flags.String("report", "", "where to write the lessons which were merged, and the near duplicates")
+	flags.Parse(args)
+
+	file, err := insidescraper.ReadSiteFile(*in)
+	if err != nil {
+		return err
+	}
+
+	dedupReport := file.Site.DeduplicateMedia()
+
+	fmt.Fprintf(os.Stderr, "Merged %d groups of duplicate lessons, and found %d near duplicates\n",
+		len(dedupReport.Merged), len(dedupReport.NearDuplicates))
+
+	if *report != "" {
+		if err := insidescraper.WriteJSON(*report, dedupReport); err != nil {
+			return err
+		}
+	}
+
+	return insidescraper.WriteSiteFile(*out, file)
+}
+
+func runCount(args []string) error {
+	flags := flag.NewFlagSet("count", flag.ExitOnError)
+	in := flags.String("in", "fixed.json", "the site data to count")
+	out := flags.String("out", "counted.json", "where to write the counted site data")
+	breakCycles := breakCyclesFlag(flags)
+	flags.Parse(args)
+
+	file, err := insidescraper.ReadSiteFile(*in)
+	if err != nil {
+		return err
+	}
+
+	file.Site = count(breakCycles(file.Site))
+	return insidescraper.WriteSiteFile(*out, file)
+}
+
+func runMigrate(args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	in := flags.String("in", "scraped.json", "the site data to migrate")
+	out := flags.String("out", "", "where to write the migrated site data (default: replace the input)")
+	flags.Parse(args)
+
+	if *out == "" {
+		*out = *in
+	}
+
+	file, err := insidescraper.ReadSiteFile(*in)
+	if err != nil {
+		return err
+	}
+
+	return insidescraper.WriteSiteFile(*out, file)
+}
+
+func runValidate(args []string) error {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	in := flags.String("in", "fixed.json", "the site data to validate")
+	out := flags.String("out", "", "where to write the findings")
+	maxErrors := flags.Int("max-errors", 0, "fail if there are more errors than this (-1 for no limit)")
+	maxWarnings := flags.Int("max-warnings", -1, "fail if there are more warnings than this (-1 for no limit)")
+	maxKinds := flags.String("max", "", "comma separated limits of particular kinds of findings, eg empty-section=10")
+	flags.Parse(args)
+
+	kindLimits, err := insidescraper.ParseKindLimits(*maxKinds)
+	if err != nil {
+		return err
+	}
+
+	limits := insidescraper.FindingLimits{
+		Errors:   *maxErrors,
+		Warnings: *maxWarnings,
+		Kinds:    kindLimits,
+	}
+
+	site, err := insidescraper.ReadSite(*in)
+	if err != nil {
+		return err
+	}
+
+	findings := site.Validate()
+	for _, finding := range findings {
+		fmt.Printf("%s %s %s: %s\n", finding.Severity, finding.Kind, finding.ID, finding.Message)
+	}
+
+	if *out != "" {
+		if err := insidescraper.WriteJSON(*out, findings); err != nil {
+			return err
+		}
+	}
+
+	if exceeded := limits.Exceeded(findings); len(exceeded) > 0 {
+		return fmt.Errorf("too many findings: %s", strings.Join(exceeded, ", "))
+	}
+
+	fmt.Fprintf(os.Stderr, "Found %d problems\n", len(findings))
+	return nil
+}
+
+func runGraph(args []string) error {
+	flags := flag.NewFlagSet("graph", flag.ExitOnError)
+	in := flags.String("in", "fixed.json", "the site data to analyze")
+	out := flags.String("out", "", "where to write the analysis")
+	flags.Parse(args)
+
+	site, err := insidescraper.ReadSite(*in)
+	if err != nil {
+		return err
+	}
+
+	graph := site.AnalyzeSections()
+
+	for _, cycle := range graph.Cycles {
+		fmt.Println("cycle: " + strings.Join(cycle, " -> ") + " -> " + cycle[0])
+	}
+	fmt.Printf("%d cycles, %d shared sections, %d unreachable sections, maximum depth %d\n",
+		len(graph.Cycles), len(graph.SharedSections), len(graph.Unreachable), graph.MaxDepth)
+
+	if *out != "" {
+		return insidescraper.WriteJSON(*out, graph)
+	}
+
+	return nil
+}
+
+func runMerge(args []string) error {
+	flags := flag.NewFlagSet("merge", flag.ExitOnError)
+	in := flags.String("in", "scraped.json", "the site data to merge into")
+	with := flags.String("with", "", "the site data to merge, such as a scrape of one branch of the site")
+	out := flags.String("out", "merged.json", "where to write the merged site data")
+	policy := flags.String("policy", string(insidescraper.PreferNewer),
+		"what to do with items which are in both: prefer-newer, prefer-existing or fail-on-conflict")
+	report := flags.String("report", "", "where to write the items which were added, replaced, kept and removed")
+	flags.Parse(args)
+
+	if *with == "" {
+		return fmt.Errorf("-with is required")
+	}
+
+	file, err := insidescraper.ReadSiteFile(*in)
+	if err != nil {
+		return err
+	}
+
+	other, err := insidescraper.ReadSiteFile(*with)
+	if err != nil {
+		return err
+	}
+
+	mergeReport, err := file.Merge(other, insidescraper.MergePolicy(*policy))
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Added %d sections and %d lessons, replaced %d sections and %d lessons, and removed %d lessons\n",
+		len(mergeReport.Sections.Added), len(mergeReport.Lessons.Added),
+		len(mergeReport.Sections.Replaced), len(mergeReport.Lessons.Replaced), len(mergeReport.Lessons.Removed))
+
+	if *report != "" {
+		if err := insidescraper.WriteJSON(*report, mergeReport); err != nil {
+			return err
+		}
+	}
+
+	return insidescraper.WriteSiteFile(*out, file)
+}
+
+func runDiff(args []string) error {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	before := flags.String("old", "", "the older site data")
+	after := flags.String("new", "", "the newer site data")
+	resolved := flags.Bool("resolved", false, "the files are resolved site data")
+	out := flags.String("out", "", "where to write the changes as JSON")
+	flags.Parse(args)
+
+	if *before == "" || *after == "" {
+		return fmt.Errorf("-old and -new are required")
+	}
+
+	var diff insidescraper.SiteDiff
+
+	if *resolved {
+		oldSite, err := insidescraper.ReadResolvedSite(*before)
+		if err != nil {
+			return err
+		}
+		newSite, err := insidescraper.ReadResolvedSite(*after)
+		if err != nil {
+			return err
+		}
+		diff = insidescraper.DiffResolvedSites(oldSite, newSite)
+	} else {
+		oldSite, err := insidescraper.ReadSite(*before)
+		if err != nil {
+			return err
+		}
+		newSite, err := insidescraper.ReadSite(*after)
+		if err != nil {
+			return err
+		}
+		diff = insidescraper.DiffSites(oldSite, newSite)
+	}
+
+	fmt.Print(diff.String())
+
+	if *out != "" {
+		return insidescraper.WriteJSON(*out, diff)
+	}
+
+	return nil
+}
+
+func runResolve(args []string) error {
+	flags := flag.NewFlagSet("resolve", flag.ExitOnError)
+	in := flags.String("in", "counted.json", "the counted site data to resolve")
+	out := flags.String("out", "resolved.json", "where to write the resolved site data")
+	breakCycles := breakCyclesFlag(flags)
+	flags.Parse(args)
+
+	site, err := insidescraper.ReadSite(*in)
+	if err != nil {
+		return err
+	}
+
+	return insidescraper.WriteJSON(*out, resolve(breakCycles(site)))
+}
+
+func runAll(args []string) error {
+	flags := flag.NewFlagSet("all", flag.ExitOnError)
+	options := scraperFlags(flags)
+	report := flags.String("report", "", "where to write the report of problems found while scraping")
+	out := flags.String("out", "full_run_data.json", "where to write the fixed and counted site data")
+	resolved := flags.String("resolved", "full_run_data.resolved.json", "where to write the resolved site data")
+	breakCycles := breakCyclesFlag(flags)
+	flags.Parse(args)
+
+	scraperOptions, err := options()
+	if err != nil {
+		return err
+	}
+
+	file, err := scrape(scraperOptions, *report)
+	if err != nil {
+		return err
+	}
+
+	file.Site = count(breakCycles(fix(file.Site, scraperOptions.Transport, scraperOptions.Profile)))
+
+	if err := insidescraper.WriteSiteFile(*out, file); err != nil {
+		return err
+	}
+
+	return insidescraper.WriteJSON(*resolved, resolve(file.Site))
+}
+
+// scraperFlags defines the flags which configure the scraper. The returned
+// function builds the options once the flags are parsed.
+func scraperFlags(flags *flag.FlagSet) func() (insidescraper.ScraperOptions, error) {
+	defaults := insidescraper.DefaultScraperOptions()
+	url := flags.String("url", defaults.BaseURL, "the page to start scraping from")
+	domains := flags.String("domains", strings.Join(defaults.AllowedDomains, ","), "comma separated list of domains which may be scraped")
+	userAgent := flags.String("user-agent", defaults.UserAgent, "the user agent to scrape with")
+	parallelism := flags.Int("parallel", 1, "how many pages to request at once")
+	checkpoint := flags.String("checkpoint", "", "save the state of the scrape to this file, so it can be resumed")
+	checkpointInterval := flags.Duration("checkpoint-interval", time.Minute, "how often to save the checkpoint")
+	resume := flags.Bool("resume", false, "continue from the last checkpoint, without revisiting finished pages")
+	fallbackImage := flags.String("fallback-image", "", "the image of top level sections which don't have one")
+	imageDir := flags.String("image-dir", "", "download the images of the top level sections to this directory")
+	strict := flags.Bool("strict", false, "stop at the first problem")
+	sortAudio := flags.Bool("sort-audio", false, "sort the classes of each lesson by the numbers in their titles")
+	profile := profileFlag(flags)
+	resolveLinks := flags.Bool("resolve-description-links", false, "follow the links in descriptions to find the sections they redirect to, with a HEAD request for each one")
+	redirectCache := flags.String("redirect-cache", "", "load and save where links redirect to in this file")
+	transport := transportFlags(flags)
+
+	return func() (insidescraper.ScraperOptions, error) {
+		options := insidescraper.ScraperOptions{
+			BaseURL:                 *url,
+			AllowedDomains:          strings.Split(*domains, ","),
+			UserAgent:               *userAgent,
+			Parallelism:             *parallelism,
+			CheckpointPath:          *checkpoint,
+			CheckpointInterval:      *checkpointInterval,
+			Resume:                  *resume,
+			FallbackImage:           *fallbackImage,
+			ImageDir:                *imageDir,
+			Strict:                  *strict,
+			SortAudio:               *sortAudio,
+			Transport:               transport(),
+			ResolveDescriptionLinks: *resolveLinks,
+		}
+
+		var err error
+		if options.Profile, err = profile(); err != nil {
+			return options, err
+		}
+
+		options.URLResolver, err = insidescraper.NewRedirectCache(*redirectCache, options.Transport)
+		return options, err
+	}
+}
+
+// profileFlag defines the flag which loads a site profile. The returned function
+// loads it once the flags are parsed; if the flag isn't set, the default profile is used.
+func profileFlag(flags *flag.FlagSet) func() (insidescraper.SiteProfile, error) {
+	path := flags.String("profile", "", "load the site's selectors from this JSON or YAML file")
+
+	return func() (insidescraper.SiteProfile, error) {
+		if *path == "" {
+			return insidescraper.DefaultSiteProfile(), nil
+		}
+		return insidescraper.LoadSiteProfile(*path)
+	}
+}
+
+// breakCyclesFlag defines the flag which breaks cycles in the section hierarchy.
+// The returned function breaks them if the flag was set.
+func breakCyclesFlag(flags *flag.FlagSet) func(insidescraper.Site) insidescraper.Site {
+	breakCycles := flags.Bool("break-cycles", false, "remove the links which make sections contain themselves")
+
+	return func(site insidescraper.Site) insidescraper.Site {
+		if *breakCycles {
+			if removed := site.BreakCycles(); len(removed) > 0 {
+				fmt.Fprintf(os.Stderr, "Removed %d links to break cycles\n", len(removed))
+			}
+		}
+		return site
+	}
+}
+
+// transportFlags defines the flags which record or replay requests. The returned
+// function builds the transport once the flags are parsed; it's nil if neither was set.
+func transportFlags(flags *flag.FlagSet) func() http.RoundTripper {
+	record := flags.String("record", "", "save every response to this fixture directory")
+	replay := flags.String("replay", "", "serve every request from this fixture directory, instead of the network")
+
+	return func() http.RoundTripper {
+		if *replay != "" {
+			return &insidescraper.ReplayTransport{Dir: *replay}
+		}
+		if *record != "" {
+			return &insidescraper.RecordingTransport{Dir: *record}
+		}
+		return nil
+	}
+}
+
+// scrape scrapes the site. If reportPath isn't empty, the report of problems is written there.
+func scrape(options insidescraper.ScraperOptions, reportPath string) (insidescraper.SiteFile, error) {
+	scraper := insidescraper.InsideScraper{
+		Options: options,
+	}
+
+	start := time.Now()
+	report, scrapeErr := scraper.Scrape()
+	end := time.Now()
+
+	file := insidescraper.SiteFile{
+		SourceURL: options.BaseURL,
+		StartTime: &start,
+		EndTime:   &end,
+		Site:      scraper.Site,
+	}
+
+	if reportPath != "" {
+		if err := insidescraper.WriteJSON(reportPath, report); err != nil {
+			return file, err
+		}
+	}
+
+	// The redirects which were found are saved even if the scrape failed, so
+	// that they don't have to be found again.
+	if cache, isCache := options.URLResolver.(*insidescraper.RedirectCache); isCache {
+		if err := cache.Save(); err != nil {
+			return file, err
+		}
+	}
+
+	if scrapeErr != nil {
+		return file, scrapeErr
+	}
+
+	fmt.Fprintf(os.Stderr, "Scraped %d sections and %d lessons, with %d problems\n",
+		len(scraper.Site.Sections), len(scraper.Site.Lessons), len(report.Problems))
+
+	return file, nil
+}
+
+func fix(site insidescraper.Site, transport http.RoundTripper, profile insidescraper.SiteProfile) insidescraper.Site {
+	postScraper := insidescraper.PostScraper{
+		Site:      site,
+		Transport: transport,
+		Profile:   profile,
+	}
+	postScraper.FixSite()
+
+	if len(postScraper.Problems) > 0 {
+		fmt.Fprintf(os.Stderr, "Fixed the site, with %d problems\n", len(postScraper.Problems))
+		for _, problem := range postScraper.Problems {
+			fmt.Fprintln(os.Stderr, problem.Error())
+		}
+	}
+
+	return postScraper.Site
+}
+
+func count(site insidescraper.Site) insidescraper.Site {
+	counter := insidescraper.MakeCounter(&site)
+	counter.CountLessons()
+
+	if hours := counter.Hours(); hours > 0 {
+		fmt.Fprintf(os.Stderr, "Counted %.1f hours of audio\n", hours)
+	}
+
+	return site
+}
+
+func resolve(site insidescraper.Site) insidescraper.ResolvedSite {
+	resolver := insidescraper.SectionResolver{
+		Site: site,
+	}
+	resolver.ResolveSite()
+	return resolver.ResolvedSite
+}
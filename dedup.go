@@ -0,0 +1,222 @@
+package insidescraper
+
+import (
+	"sort"
+	"strings"
+)
+
+// DuplicateLessons are lessons which have the same audio.
+type DuplicateLessons struct {
+	// Lessons are the IDs of the lessons, sorted.
+	Lessons []string
+	// Canonical is the lesson which exact duplicates were merged into.
+	Canonical string `json:",omitempty"`
+	// Shared are the audio sources which near duplicates have in common.
+	Shared []string `json:",omitempty"`
+}
+
+// DedupReport lists the duplicate lessons which were found.
+type DedupReport struct {
+	// Merged are lessons with exactly the same audio, which were merged.
+	Merged []DuplicateLessons
+	// NearDuplicates are lessons which share some audio, but not all of it.
+	// They're left alone, to be reviewed.
+	NearDuplicates []DuplicateLessons
+}
+
+// DeduplicateMedia finds lessons which have the same audio; the site often has
+// the same classes in a few sections, and copies rows between the mobile and
+// desktop layouts. Lessons with exactly the same audio are merged into one
+// canonical lesson, which every section which had one of them references.
+// Lessons which only share some audio are reported.
+func (site *Site) DeduplicateMedia() DedupReport {
+	report := DedupReport{
+		Merged:         make([]DuplicateLessons, 0),
+		NearDuplicates: make([]DuplicateLessons, 0),
+	}
+
+	// Group the lessons by their audio.
+	groups := make(map[string][]string, len(site.Lessons))
+	for id, lesson := range site.Lessons {
+		if sources := getAudioSources(lesson); len(sources) > 0 {
+			key := strings.Join(sources, "\n")
+			groups[key] = append(groups[key], id)
+		}
+	}
+
+	// What each merged lesson was replaced with.
+	replacements := make(map[string]string, len(site.Lessons))
+
+	for _, ids := range groups {
+		if len(ids) < 2 {
+			continue
+		}
+
+		sort.Strings(ids)
+		canonical := ids[0]
+		for _, id := range ids[1:] {
+			site.mergeLesson(canonical, id)
+			replacements[id] = canonical
+		}
+
+		report.Merged = append(report.Merged, DuplicateLessons{
+			Lessons:   ids,
+			Canonical: canonical,
+		})
+	}
+
+	site.replaceLessons(replacements)
+	report.NearDuplicates = site.findNearDuplicates()
+
+	sort.Slice(report.Merged, func(i, j int) bool {
+		return report.Merged[i].Canonical < report.Merged[j].Canonical
+	})
+
+	return report
+}
+
+// getAudioSources gets the sorted, unique audio sources of the lesson.
+func getAudioSources(lesson Lesson) []string {
+	sources := make([]string, 0, len(lesson.Audio))
+	seen := make(map[string]bool, len(lesson.Audio))
+
+	for _, media := range lesson.Audio {
+		if media.Source != "" && !seen[media.Source] {
+			seen[media.Source] = true
+			sources = append(sources, media.Source)
+		}
+	}
+
+	sort.Strings(sources)
+	return sources
+}
+
+// mergeLesson merges the duplicate into the canonical lesson, and deletes it.
+// The canonical lesson gets any PDFs and videos which only the duplicate has,
+// and its description if it doesn't have one.
+func (site *Site) mergeLesson(canonicalID, duplicateID string) {
+	canonical := site.Lessons[canonicalID]
+	duplicate := site.Lessons[duplicateID]
+
+	if canonical.SiteData == nil {
+		canonical.SiteData = &SiteData{}
+	}
+
+	if duplicate.SiteData != nil {
+		// Copy the data, because it may be shared with a section.
+		data := *canonical.SiteData
+		canonical.SiteData = &data
+
+		if canonical.Description == "" {
+			canonical.Description = duplicate.Description
+			canonical.DescriptionHTML = duplicate.DescriptionHTML
+		}
+
+		for _, pdf := range duplicate.Pdf {
+			if !hasDocument(canonical.Pdf, pdf.URL) {
+				canonical.Pdf = append(canonical.Pdf, pdf)
+			}
+		}
+	}
+
+	for _, video := range duplicate.Video {
+		if !hasMedia(canonical.Video, video.Source) {
+			canonical.Video = append(canonical.Video, video)
+		}
+	}
+
+	site.Lessons[canonicalID] = canonical
+	delete(site.Lessons, duplicateID)
+}
+
+func hasDocument(documents []Document, url string) bool {
+	for _, document := range documents {
+		if document.URL == url {
+			return true
+		}
+	}
+	return false
+}
+
+func hasMedia(media []Media, source string) bool {
+	for _, item := range media {
+		if item.Source == source {
+			return true
+		}
+	}
+	return false
+}
+
+// replaceLessons changes every reference to a lesson which was merged into a
+// reference to its canonical lesson. A section which had a few copies of it
+// only keeps one.
+func (site *Site) replaceLessons(replacements map[string]string) {
+	if len(replacements) == 0 {
+		return
+	}
+
+	for id, section := range site.Sections {
+		lessons := make([]string, 0, len(section.Lessons))
+		included := make(map[string]bool, len(section.Lessons))
+		changed := false
+
+		for _, lessonID := range section.Lessons {
+			if replacement, exists := replacements[lessonID]; exists {
+				lessonID = replacement
+				changed = true
+			}
+
+			if included[lessonID] {
+				changed = true
+				continue
+			}
+			included[lessonID] = true
+			lessons = append(lessons, lessonID)
+		}
+
+		if changed {
+			section.Lessons = lessons
+			site.setSection(id, section)
+		}
+	}
+}
+
+// findNearDuplicates finds every pair of lessons which share audio.
+func (site *Site) findNearDuplicates() []DuplicateLessons {
+	lessonsBySource := make(map[string][]string, len(site.Lessons))
+	for id, lesson := range site.Lessons {
+		for _, source := range getAudioSources(lesson) {
+			lessonsBySource[source] = append(lessonsBySource[source], id)
+		}
+	}
+
+	shared := make(map[[2]string][]string)
+	for source, ids := range lessonsBySource {
+		sort.Strings(ids)
+		for i := range ids {
+			for j := i + 1; j < len(ids); j++ {
+				pair := [2]string{ids[i], ids[j]}
+				shared[pair] = append(shared[pair], source)
+			}
+		}
+	}
+
+	nearDuplicates := make([]DuplicateLessons, 0, len(shared))
+	for pair, sources := range shared {
+		sort.Strings(sources)
+		nearDuplicates = append(nearDuplicates, DuplicateLessons{
+			Lessons: []string{pair[0], pair[1]},
+			Shared:  sources,
+		})
+	}
+
+	sort.Slice(nearDuplicates, func(i, j int) bool {
+		a, b := nearDuplicates[i].Lessons, nearDuplicates[j].Lessons
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		return a[1] < b[1]
+	})
+
+	return nearDuplicates
+}
@@ -0,0 +1,65 @@
+package insidescraper
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDeduplicateMedia(t *testing.T) {
+	audio := func(sources ...string) []Media {
+		media := make([]Media, 0, len(sources))
+		for _, source := range sources {
+			media = append(media, Media{SiteData: &SiteData{}, Source: source})
+		}
+		return media
+	}
+
+	site := Site{
+		Sections: map[string]SiteSection{
+			"a": {SiteData: &SiteData{}, ID: "a", Lessons: []string{"a1", "a2"}},
+			"b": {SiteData: &SiteData{}, ID: "b", Lessons: []string{"b1"}},
+			// The mobile and desktop copies of the same row.
+			"c": {SiteData: &SiteData{}, ID: "c", Lessons: []string{"c1", "c1-mobile"}},
+		},
+		Lessons: map[string]Lesson{
+			"a1": {SiteData: &SiteData{Title: "One"}, ID: "a1", Audio: audio("1.mp3", "2.mp3")},
+			"a2": {SiteData: &SiteData{Title: "Three"}, ID: "a2", Audio: audio("3.mp3", "4.mp3")},
+			"b1": {SiteData: &SiteData{Title: "One again", Description: "Shared",
+				Pdf: []Document{{URL: "1.pdf"}}}, ID: "b1", Audio: audio("2.mp3", "1.mp3")},
+			"c1":        {SiteData: &SiteData{}, ID: "c1", Audio: audio("3.mp3")},
+			"c1-mobile": {SiteData: &SiteData{}, ID: "c1-mobile", Audio: audio("3.mp3")},
+		},
+	}
+
+	report := site.DeduplicateMedia()
+
+	expectedMerged := []DuplicateLessons{
+		{Lessons: []string{"a1", "b1"}, Canonical: "a1"},
+		{Lessons: []string{"c1", "c1-mobile"}, Canonical: "c1"},
+	}
+	if !reflect.DeepEqual(report.Merged, expectedMerged) {
+		t.Errorf("Expected merged %+v, got %+v", expectedMerged, report.Merged)
+	}
+
+	expectedNear := []DuplicateLessons{
+		{Lessons: []string{"a2", "c1"}, Shared: []string{"3.mp3"}},
+	}
+	if !reflect.DeepEqual(report.NearDuplicates, expectedNear) {
+		t.Errorf("Expected near duplicates %+v, got %+v", expectedNear, report.NearDuplicates)
+	}
+
+	if _, exists := site.Lessons["b1"]; exists {
+		t.Error("Expected the duplicate lesson to be removed")
+	}
+	if lessons := site.Sections["b"].Lessons; !reflect.DeepEqual(lessons, []string{"a1"}) {
+		t.Errorf("Expected section b to reference the canonical lesson, got %v", lessons)
+	}
+	if lessons := site.Sections["c"].Lessons; !reflect.DeepEqual(lessons, []string{"c1"}) {
+		t.Errorf("Expected section c to reference the lesson once, got %v", lessons)
+	}
+
+	canonical := site.Lessons["a1"]
+	if canonical.Title != "One" || canonical.Description != "Shared" || len(canonical.Pdf) != 1 {
+		t.Errorf("Expected the canonical lesson to get the duplicate's description and PDF, got %+v", *canonical.SiteData)
+	}
+}
@@ -0,0 +1,210 @@
+package insidescraper
+
+import (
+	"html"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Elements which are kept in rich descriptions. Any other element is replaced
+// by its content, except for droppedElements, which are removed with their content.
+var (
+	descriptionElements = map[string]bool{
+		"p": true, "br": true, "a": true, "strong": true, "b": true, "em": true,
+		"i": true, "u": true, "ul": true, "ol": true, "li": true, "blockquote": true,
+		"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	}
+	droppedElements = map[string]bool{
+		"script": true, "style": true, "noscript": true, "iframe": true, "object": true,
+		"embed": true, "form": true, "input": true, "button": true, "img": true,
+		"audio": true, "video": true,
+	}
+	// blockElements start a new line of a description.
+	blockElements = map[string]bool{
+		"p": true, "div": true, "ul": true, "ol": true, "blockquote": true,
+		"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	}
+)
+
+// sanitizeHTML gets the HTML of the selection's content, with only the
+// elements which format text. Links are made absolute, from the URL of the page.
+func sanitizeHTML(selection *goquery.Selection, pageURL string) string {
+	var builder strings.Builder
+	writeSanitizedHTML(&builder, selection.Contents(), pageURL)
+	return strings.TrimSpace(builder.String())
+}
+
+func writeSanitizedHTML(builder *strings.Builder, nodes *goquery.Selection, pageURL string) {
+	nodes.Each(func(_ int, s *goquery.Selection) {
+		name := goquery.NodeName(s)
+
+		switch {
+		case name == "#text":
+			builder.WriteString(html.EscapeString(s.Text()))
+		case name == "br":
+			builder.WriteString("<br>")
+		case descriptionElements[name]:
+			builder.WriteString("<" + name)
+			if href := getAbsoluteHref(s, pageURL); name == "a" && href != "" {
+				builder.WriteString(` href="` + html.EscapeString(href) + `"`)
+			}
+			builder.WriteString(">")
+			writeSanitizedHTML(builder, s.Contents(), pageURL)
+			builder.WriteString("</" + name + ">")
+		case droppedElements[name] || strings.HasPrefix(name, "#"):
+			// Comments etc aren't part of the description.
+		default:
+			writeSanitizedHTML(builder, s.Contents(), pageURL)
+		}
+	})
+}
+
+// getAbsoluteHref gets the link's URL, resolved from the URL of the page. Only
+// web and email links are kept.
+func getAbsoluteHref(link *goquery.Selection, pageURL string) string {
+	href, err := url.Parse(strings.TrimSpace(link.AttrOr("href", "")))
+	if err != nil || href.String() == "" {
+		return ""
+	}
+
+	if base, err := url.Parse(pageURL); err == nil && pageURL != "" {
+		href = base.ResolveReference(href)
+	}
+
+	switch href.Scheme {
+	case "http", "https", "mailto":
+		return href.String()
+	}
+
+	return ""
+}
+
+// descriptionLine is one line of a description, as text and as HTML.
+type descriptionLine struct {
+	Text string
+	HTML string
+}
+
+// getDescriptionLines splits the selection's content into lines, at line breaks
+// and block elements.
+func getDescriptionLines(selection *goquery.Selection, pageURL string) []descriptionLine {
+	var lines []descriptionLine
+	// The parts of the line which is being formed.
+	var text, rich strings.Builder
+
+	endLine := func() {
+		if strings.TrimSpace(text.String()) != "" {
+			lines = append(lines, descriptionLine{
+				Text: strings.TrimSpace(text.String()),
+				HTML: strings.TrimSpace(rich.String()),
+			})
+		}
+		text.Reset()
+		rich.Reset()
+	}
+
+	selection.Contents().Each(func(_ int, s *goquery.Selection) {
+		name := goquery.NodeName(s)
+
+		switch {
+		case name == "br":
+			endLine()
+		case name == "#text":
+			// Like the text description, a new line in the source starts a new line.
+			for i, part := range strings.Split(s.Text(), "\n") {
+				if i > 0 {
+					endLine()
+				}
+				text.WriteString(part)
+				rich.WriteString(html.EscapeString(part))
+			}
+		case blockElements[name]:
+			endLine()
+			text.WriteString(s.Text())
+			writeSanitizedHTML(&rich, s, pageURL)
+			endLine()
+		case !droppedElements[name] && !strings.HasPrefix(name, "#"):
+			text.WriteString(s.Text())
+			writeSanitizedHTML(&rich, s, pageURL)
+		}
+	})
+	endLine()
+
+	return lines
+}
+
+// separateHTML separates two pieces of HTML with a line break.
+func separateHTML(html1, html2 string) string {
+	if html1 == "" {
+		return strings.TrimSpace(html2)
+	}
+	return strings.TrimSpace(html1 + "<br>" + html2)
+}
+
+// LinkDescriptions marks the links in the rich descriptions of the site which
+// lead to a section or lesson on the site: the link's URL is changed to the ID,
+// and a data-section or data-lesson attribute holds it too, so that an app can
+// open it itself. Links which aren't IDs are resolved with the resolver, if it
+// isn't nil.
+func (site *Site) LinkDescriptions(resolver URLResolver) {
+	datas := make([]*SiteData, 0, len(site.Sections)+len(site.Lessons))
+	for _, section := range site.Sections {
+		datas = append(datas, section.SiteData)
+	}
+	for _, lesson := range site.Lessons {
+		datas = append(datas, lesson.SiteData)
+		for _, media := range append(append([]Media{}, lesson.Audio...), lesson.Video...) {
+			datas = append(datas, media.SiteData)
+		}
+	}
+
+	for _, data := range datas {
+		if data == nil || !strings.Contains(data.DescriptionHTML, "<a") {
+			continue
+		}
+		data.DescriptionHTML = site.linkDescription(data.DescriptionHTML, resolver)
+	}
+}
+
+func (site *Site) linkDescription(description string, resolver URLResolver) string {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(description))
+	if err != nil {
+		return description
+	}
+
+	doc.Find("a[href]").Each(func(_ int, link *goquery.Selection) {
+		href, _ := link.Attr("href")
+
+		if site.setLinkID(link, href) || resolver == nil {
+			return
+		}
+		if resolved, err := resolver.Resolve(href); err == nil {
+			site.setLinkID(link, resolved)
+		}
+	})
+
+	linked, err := doc.Find("body").Html()
+	if err != nil {
+		return description
+	}
+
+	return linked
+}
+
+// setLinkID marks the link, if the ID is of a section or lesson.
+func (site *Site) setLinkID(link *goquery.Selection, id string) bool {
+	if _, exists := site.Sections[id]; exists {
+		link.SetAttr("href", id)
+		link.SetAttr("data-section", id)
+		return true
+	}
+	if _, exists := site.Lessons[id]; exists {
+		link.SetAttr("href", id)
+		link.SetAttr("data-lesson", id)
+		return true
+	}
+
+	return false
+}
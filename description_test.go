@@ -0,0 +1,76 @@
+package insidescraper
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestSanitizeHTML(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<div id="description">
+		<p class="intro" style="color: red">The <strong>first</strong> class<script>alert("hi")</script></p>
+		<span>From <a href="/sources/1.pdf" onclick="track()">the source</a></span>
+		<a href="javascript:alert('hi')">Bad link</a><img src="/image.png">
+	</div>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sanitized := sanitizeHTML(doc.Find("#description"), "https://example.com/section/page")
+	expected := `<p>The <strong>first</strong> class</p>
+		From <a href="https://example.com/sources/1.pdf">the source</a>
+		<a>Bad link</a>`
+
+	if sanitized != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, sanitized)
+	}
+}
+
+func TestRichDescriptions(t *testing.T) {
+	server := newFakeSite()
+	defer server.Close()
+
+	options := fakeSiteOptions(server)
+	options.ResolveDescriptionLinks = true
+	scraper := InsideScraper{Options: options}
+	if _, err := scraper.Scrape(); err != nil {
+		t.Fatal(err)
+	}
+
+	sub := scraper.Site.Sections[server.URL+"/section-a/sub"]
+	if sub.Description != "A sub section. See section B" {
+		t.Errorf("Expected the text description to be kept, got %q", sub.Description)
+	}
+
+	// The link to /b leads to section B, so it's changed to its ID.
+	sectionB := server.URL + "/section-b"
+	expected := `A sub section. <b>See</b> <a href="` + sectionB + `" data-section="` + sectionB + `">section B</a>`
+	if sub.DescriptionHTML != expected {
+		t.Errorf("Expected the rich description %s, got %s", expected, sub.DescriptionHTML)
+	}
+
+	lesson := scraper.Site.Lessons[sub.Lessons[0]]
+	for i, description := range []string{"The first class", "The second class"} {
+		if html := lesson.Audio[i].DescriptionHTML; html != description {
+			t.Errorf("Expected class %d to have the rich description %q, got %q", i+1, description, html)
+		}
+	}
+}
+
+func TestDescriptionLinksNotResolved(t *testing.T) {
+	server := newFakeSite()
+	defer server.Close()
+
+	scraper := InsideScraper{Options: fakeSiteOptions(server)}
+	if _, err := scraper.Scrape(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without ResolveDescriptionLinks, the link to /b isn't followed.
+	sub := scraper.Site.Sections[server.URL+"/section-a/sub"]
+	expected := `A sub section. <b>See</b> <a href="` + server.URL + `/b">section B</a>`
+	if sub.DescriptionHTML != expected {
+		t.Errorf("Expected the rich description %s, got %s", expected, sub.DescriptionHTML)
+	}
+}
@@ -0,0 +1,335 @@
+package insidescraper
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ChangeKind is the kind of a change between two snapshots of the site.
+type ChangeKind string
+
+const (
+	// ChangeAdded is an item which is only in the new snapshot.
+	ChangeAdded ChangeKind = "added"
+	// ChangeRemoved is an item which is only in the old snapshot.
+	ChangeRemoved ChangeKind = "removed"
+	// ChangeMoved is an item which is in different sections.
+	ChangeMoved ChangeKind = "moved"
+	// TitleChanged is an item whose title changed.
+	TitleChanged ChangeKind = "title"
+	// DescriptionChanged is an item whose description changed.
+	DescriptionChanged ChangeKind = "description"
+	// AudioAdded is an audio source which an item gained.
+	AudioAdded ChangeKind = "audio-added"
+	// AudioRemoved is an audio source which an item lost.
+	AudioRemoved ChangeKind = "audio-removed"
+)
+
+// Change is one difference between two snapshots of the site.
+type Change struct {
+	Kind ChangeKind
+	// Type is whether the item is a section or a lesson.
+	Type DataType
+	ID   string
+	// Old and New are the title or description before and after, or the audio
+	// source which was added or removed.
+	Old string `json:",omitempty"`
+	New string `json:",omitempty"`
+	// OldParents and NewParents are the sections which contained a moved item.
+	OldParents []string `json:",omitempty"`
+	NewParents []string `json:",omitempty"`
+}
+
+// MarshalJSON encodes the change with its type as a name, like "section". Only
+// changes are encoded this way; the resolved site keeps the numbers the app reads.
+func (change Change) MarshalJSON() ([]byte, error) {
+	// A type without the MarshalJSON method, so that the default encoding is used.
+	type plainChange Change
+
+	return json.Marshal(struct {
+		plainChange
+		Type string
+	}{plainChange(change), change.Type.String()})
+}
+
+// CountChange is the change in the number of audio classes of a top level section.
+type CountChange struct {
+	ID    string
+	Title string
+	Old   int
+	New   int
+}
+
+// SiteDiff lists what changed between two snapshots of the site.
+type SiteDiff struct {
+	Changes     []Change
+	AudioCounts []CountChange
+}
+
+// diffItem is what's compared of a section or lesson.
+type diffItem struct {
+	title       string
+	description string
+	// parents and audio are sorted.
+	parents []string
+	audio   []string
+}
+
+// diffSnapshot is what's compared of a site, whether it's resolved or not.
+type diffSnapshot struct {
+	sections    map[string]diffItem
+	lessons     map[string]diffItem
+	topLevel    []string
+	audioCounts map[string]int
+}
+
+// DiffSites finds what changed from the old site to the new one.
+func DiffSites(before, after Site) SiteDiff {
+	return diffSnapshots(getSiteSnapshot(&before), getSiteSnapshot(&after))
+}
+
+// DiffResolvedSites finds what changed from the old resolved site to the new
+// one. Audio which was resolved into a section counts as the section's.
+func DiffResolvedSites(before, after ResolvedSite) SiteDiff {
+	return diffSnapshots(getResolvedSnapshot(before), getResolvedSnapshot(after))
+}
+
+func getSiteSnapshot(site *Site) diffSnapshot {
+	snapshot := newDiffSnapshot(site.TopLevel, len(site.Sections), len(site.Lessons))
+
+	for id, section := range site.Sections {
+		item := getDiffItem(section.SiteData)
+		item.parents = site.sectionParents(id)
+		snapshot.sections[id] = item
+		snapshot.audioCounts[id] = section.AudioCount
+	}
+
+	for id, lesson := range site.Lessons {
+		item := getDiffItem(lesson.SiteData)
+		item.parents = site.lessonParents(id)
+		item.audio = getAudioSources(lesson)
+		snapshot.lessons[id] = item
+	}
+
+	return snapshot
+}
+
+func getResolvedSnapshot(site ResolvedSite) diffSnapshot {
+	snapshot := newDiffSnapshot(site.TopLevel, len(site.Sections), len(site.Lessons))
+	parents := map[DataType]map[string]map[string]bool{
+		SectionType: make(map[string]map[string]bool, len(site.Sections)),
+		LessonType:  make(map[string]map[string]bool, len(site.Lessons)),
+	}
+
+	for id, section := range site.Sections {
+		for _, reference := range section.Content {
+			if references, isItem := parents[reference.Type]; isItem {
+				if references[reference.Reference] == nil {
+					references[reference.Reference] = make(map[string]bool, 1)
+				}
+				references[reference.Reference][id] = true
+			}
+		}
+	}
+
+	for id, section := range site.Sections {
+		item := getDiffItem(section.SiteData)
+		item.parents = sortedKeys(parents[SectionType][id])
+		item.audio = make([]string, 0, len(section.Audio))
+		for source := range section.Audio {
+			item.audio = append(item.audio, source)
+		}
+		sort.Strings(item.audio)
+		snapshot.sections[id] = item
+		snapshot.audioCounts[id] = section.AudioCount
+	}
+
+	for id, lesson := range site.Lessons {
+		item := getDiffItem(lesson.SiteData)
+		item.parents = sortedKeys(parents[LessonType][id])
+		item.audio = getAudioSources(lesson)
+		snapshot.lessons[id] = item
+	}
+
+	return snapshot
+}
+
+func newDiffSnapshot(topLevel []TopItem, sections, lessons int) diffSnapshot {
+	snapshot := diffSnapshot{
+		sections:    make(map[string]diffItem, sections),
+		lessons:     make(map[string]diffItem, lessons),
+		topLevel:    make([]string, 0, len(topLevel)),
+		audioCounts: make(map[string]int, sections),
+	}
+
+	for _, item := range topLevel {
+		snapshot.topLevel = append(snapshot.topLevel, item.ID)
+	}
+
+	return snapshot
+}
+
+func getDiffItem(data *SiteData) diffItem {
+	if data == nil {
+		return diffItem{}
+	}
+	return diffItem{title: data.Title, description: data.Description}
+}
+
+func diffSnapshots(before, after diffSnapshot) SiteDiff {
+	diff := SiteDiff{
+		Changes:     make([]Change, 0),
+		AudioCounts: make([]CountChange, 0),
+	}
+
+	diff.Changes = append(diff.Changes, diffItems(SectionType, before.sections, after.sections)...)
+	diff.Changes = append(diff.Changes, diffItems(LessonType, before.lessons, after.lessons)...)
+
+	// The top level sections of either snapshot, in order.
+	topLevel := append([]string{}, after.topLevel...)
+	isTopLevel := make(map[string]bool, len(after.topLevel))
+	for _, id := range after.topLevel {
+		isTopLevel[id] = true
+	}
+	for _, id := range before.topLevel {
+		if !isTopLevel[id] {
+			topLevel = append(topLevel, id)
+		}
+	}
+
+	for _, id := range topLevel {
+		if before.audioCounts[id] == after.audioCounts[id] {
+			continue
+		}
+
+		title := after.sections[id].title
+		if _, exists := after.sections[id]; !exists {
+			title = before.sections[id].title
+		}
+
+		diff.AudioCounts = append(diff.AudioCounts, CountChange{
+			ID:    id,
+			Title: title,
+			Old:   before.audioCounts[id],
+			New:   after.audioCounts[id],
+		})
+	}
+
+	return diff
+}
+
+// diffItems finds the changes to the items of one type, sorted by ID.
+func diffItems(dataType DataType, before, after map[string]diffItem) []Change {
+	changes := make([]Change, 0)
+
+	ids := make([]string, 0, len(before)+len(after))
+	for id := range before {
+		ids = append(ids, id)
+	}
+	for id := range after {
+		if _, exists := before[id]; !exists {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		oldItem, inOld := before[id]
+		newItem, inNew := after[id]
+		change := func(kind ChangeKind, oldValue, newValue string) Change {
+			return Change{Kind: kind, Type: dataType, ID: id, Old: oldValue, New: newValue}
+		}
+
+		if !inOld {
+			changes = append(changes, change(ChangeAdded, "", newItem.title))
+			continue
+		}
+		if !inNew {
+			changes = append(changes, change(ChangeRemoved, oldItem.title, ""))
+			continue
+		}
+
+		if !equalStrings(oldItem.parents, newItem.parents) {
+			moved := change(ChangeMoved, "", "")
+			moved.OldParents = oldItem.parents
+			moved.NewParents = newItem.parents
+			changes = append(changes, moved)
+		}
+		if oldItem.title != newItem.title {
+			changes = append(changes, change(TitleChanged, oldItem.title, newItem.title))
+		}
+		if oldItem.description != newItem.description {
+			changes = append(changes, change(DescriptionChanged, oldItem.description, newItem.description))
+		}
+
+		oldAudio := make(map[string]bool, len(oldItem.audio))
+		for _, source := range oldItem.audio {
+			oldAudio[source] = true
+		}
+		newAudio := make(map[string]bool, len(newItem.audio))
+		for _, source := range newItem.audio {
+			newAudio[source] = true
+			if !oldAudio[source] {
+				changes = append(changes, change(AudioAdded, "", source))
+			}
+		}
+		for _, source := range oldItem.audio {
+			if !newAudio[source] {
+				changes = append(changes, change(AudioRemoved, source, ""))
+			}
+		}
+	}
+
+	return changes
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// String describes the changes as text, one per line, followed by the changes
+// to the number of audio classes of the top level sections.
+func (diff SiteDiff) String() string {
+	var text strings.Builder
+
+	for _, change := range diff.Changes {
+		item := change.Type.String() + " " + change.ID
+
+		switch change.Kind {
+		case ChangeAdded:
+			fmt.Fprintf(&text, "+ %s %q\n", item, change.New)
+		case ChangeRemoved:
+			fmt.Fprintf(&text, "- %s %q\n", item, change.Old)
+		case ChangeMoved:
+			fmt.Fprintf(&text, "~ %s moved from [%s] to [%s]\n", item,
+				strings.Join(change.OldParents, ", "), strings.Join(change.NewParents, ", "))
+		case TitleChanged:
+			fmt.Fprintf(&text, "~ %s title %q -> %q\n", item, change.Old, change.New)
+		case DescriptionChanged:
+			fmt.Fprintf(&text, "~ %s description changed\n", item)
+		case AudioAdded:
+			fmt.Fprintf(&text, "+ %s audio %s\n", item, change.New)
+		case AudioRemoved:
+			fmt.Fprintf(&text, "- %s audio %s\n", item, change.Old)
+		}
+	}
+
+	if len(diff.AudioCounts) > 0 {
+		text.WriteString("\nAudio classes in top level sections:\n")
+		for _, count := range diff.AudioCounts {
+			fmt.Fprintf(&text, "  %s %q: %d -> %d (%+d)\n", count.ID, count.Title, count.Old, count.New, count.New-count.Old)
+		}
+	}
+
+	return text.String()
+}
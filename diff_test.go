@@ -0,0 +1,131 @@
+package insidescraper
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffSites(t *testing.T) {
+	audio := func(sources ...string) []Media {
+		media := make([]Media, 0, len(sources))
+		for _, source := range sources {
+			media = append(media, Media{SiteData: &SiteData{}, Source: source})
+		}
+		return media
+	}
+
+	before := Site{
+		TopLevel: []TopItem{{ID: "top"}, {ID: "gone"}},
+		Sections: map[string]SiteSection{
+			"top":  {SiteData: &SiteData{Title: "Top"}, ID: "top", Sections: []string{"a"}, AudioCount: 3},
+			"a":    {SiteData: &SiteData{Title: "A"}, ID: "a", Lessons: []string{"moves", "changes"}, AudioCount: 3},
+			"gone": {SiteData: &SiteData{Title: "Gone"}, ID: "gone", AudioCount: 1},
+		},
+		Lessons: map[string]Lesson{
+			"moves":   {SiteData: &SiteData{Title: "Moves"}, ID: "moves", Audio: audio("1.mp3")},
+			"changes": {SiteData: &SiteData{Title: "Old title", Description: "Old"}, ID: "changes", Audio: audio("2.mp3", "3.mp3")},
+		},
+	}
+
+	after := Site{
+		TopLevel: []TopItem{{ID: "top"}},
+		Sections: map[string]SiteSection{
+			"top": {SiteData: &SiteData{Title: "Top"}, ID: "top", Sections: []string{"a", "b"}, AudioCount: 4},
+			"a":   {SiteData: &SiteData{Title: "A"}, ID: "a", Lessons: []string{"changes"}, AudioCount: 2},
+			"b":   {SiteData: &SiteData{Title: "B"}, ID: "b", Lessons: []string{"moves"}, AudioCount: 2},
+		},
+		Lessons: map[string]Lesson{
+			"moves":   {SiteData: &SiteData{Title: "Moves"}, ID: "moves", Audio: audio("1.mp3")},
+			"changes": {SiteData: &SiteData{Title: "New title", Description: "Old"}, ID: "changes", Audio: audio("2.mp3", "4.mp3")},
+		},
+	}
+
+	diff := DiffSites(before, after)
+
+	expected := []Change{
+		{Kind: ChangeAdded, Type: SectionType, ID: "b", New: "B"},
+		{Kind: ChangeRemoved, Type: SectionType, ID: "gone", Old: "Gone"},
+		{Kind: TitleChanged, Type: LessonType, ID: "changes", Old: "Old title", New: "New title"},
+		{Kind: AudioAdded, Type: LessonType, ID: "changes", New: "4.mp3"},
+		{Kind: AudioRemoved, Type: LessonType, ID: "changes", Old: "3.mp3"},
+		{Kind: ChangeMoved, Type: LessonType, ID: "moves", OldParents: []string{"a"}, NewParents: []string{"b"}},
+	}
+	if !reflect.DeepEqual(diff.Changes, expected) {
+		t.Errorf("Expected changes\n%+v\ngot\n%+v", expected, diff.Changes)
+	}
+
+	expectedCounts := []CountChange{
+		{ID: "top", Title: "Top", Old: 3, New: 4},
+		{ID: "gone", Title: "Gone", Old: 1, New: 0},
+	}
+	if !reflect.DeepEqual(diff.AudioCounts, expectedCounts) {
+		t.Errorf("Expected audio counts %+v, got %+v", expectedCounts, diff.AudioCounts)
+	}
+
+	text := diff.String()
+	for _, line := range []string{
+		`+ section b "B"`,
+		`~ lesson changes title "Old title" -> "New title"`,
+		`~ lesson moves moved from [a] to [b]`,
+		`top "Top": 3 -> 4 (+1)`,
+	} {
+		if !strings.Contains(text, line) {
+			t.Errorf("Expected the text to contain %q, got\n%s", line, text)
+		}
+	}
+
+	encoded, err := json.Marshal(diff.Changes[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"Kind":"added","ID":"b","New":"B","Type":"section"}`; string(encoded) != expected {
+		t.Errorf("Expected the change to be encoded as %s, got %s", expected, encoded)
+	}
+}
+
+func TestDiffResolvedSites(t *testing.T) {
+	before := ResolvedSite{
+		TopLevel: []TopItem{{ID: "top"}},
+		Sections: map[string]ResolvedSection{
+			"top": {SiteData: &SiteData{Title: "Top"}, ID: "top", AudioCount: 2,
+				Content: []ContentReference{{Type: LessonType, Reference: "lesson"}, {Type: MediaType, Reference: "1.mp3"}},
+				Audio:   map[string]Media{"1.mp3": {Source: "1.mp3"}}},
+		},
+		Lessons: map[string]Lesson{"lesson": {SiteData: &SiteData{}, ID: "lesson"}},
+	}
+	after := ResolvedSite{
+		TopLevel: []TopItem{{ID: "top"}},
+		Sections: map[string]ResolvedSection{
+			"top": {SiteData: &SiteData{Title: "Top"}, ID: "top", AudioCount: 1,
+				Content: []ContentReference{{Type: SectionType, Reference: "sub"}, {Type: MediaType, Reference: "2.mp3"}},
+				Audio:   map[string]Media{"2.mp3": {Source: "2.mp3"}}},
+			"sub": {ID: "sub", Content: []ContentReference{{Type: LessonType, Reference: "lesson"}}},
+		},
+		Lessons: map[string]Lesson{"lesson": {SiteData: &SiteData{}, ID: "lesson"}},
+	}
+
+	diff := DiffResolvedSites(before, after)
+
+	expected := []Change{
+		{Kind: ChangeAdded, Type: SectionType, ID: "sub"},
+		{Kind: AudioAdded, Type: SectionType, ID: "top", New: "2.mp3"},
+		{Kind: AudioRemoved, Type: SectionType, ID: "top", Old: "1.mp3"},
+		{Kind: ChangeMoved, Type: LessonType, ID: "lesson", OldParents: []string{"top"}, NewParents: []string{"sub"}},
+	}
+	if !reflect.DeepEqual(diff.Changes, expected) {
+		t.Errorf("Expected changes\n%+v\ngot\n%+v", expected, diff.Changes)
+	}
+
+	if expectedCounts := []CountChange{{ID: "top", Title: "Top", Old: 2, New: 1}}; !reflect.DeepEqual(diff.AudioCounts, expectedCounts) {
+		t.Errorf("Expected audio counts %+v, got %+v", expectedCounts, diff.AudioCounts)
+	}
+}
+
+func TestDiffSameSite(t *testing.T) {
+	site := getGraphSite()
+	if diff := DiffSites(site, getGraphSite()); len(diff.Changes) != 0 || len(diff.AudioCounts) != 0 {
+		t.Errorf("Expected no changes, got %+v", diff)
+	}
+}
@@ -0,0 +1,94 @@
+package insidescraper
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+)
+
+// RecordingTransport makes requests with the wrapped transport, and saves every
+// response to a fixture directory, so that the scrape can be replayed later
+// with ReplayTransport.
+// Redirects are followed by the client, so every hop is saved as its own fixture.
+type RecordingTransport struct {
+	// Dir is the fixture directory.
+	Dir string
+	// Transport makes the real requests. If nil, http.DefaultTransport is used.
+	Transport http.RoundTripper
+}
+
+// RoundTrip makes the request and records the response.
+func (recorder *RecordingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	transport := recorder.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	response, err := transport.RoundTrip(request)
+	if err != nil {
+		return nil, err
+	}
+
+	dump, err := httputil.DumpResponse(response, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(recorder.Dir, 0755); err != nil {
+		return nil, err
+	}
+
+	if err := ioutil.WriteFile(fixturePath(recorder.Dir, request), dump, 0644); err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// ReplayTransport serves responses from a fixture directory which was recorded
+// by RecordingTransport. It never touches the network; a request which wasn't
+// recorded fails.
+type ReplayTransport struct {
+	// Dir is the fixture directory.
+	Dir string
+}
+
+// RoundTrip returns the recorded response to the request.
+func (replayer *ReplayTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	dump, err := ioutil.ReadFile(fixturePath(replayer.Dir, request))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no fixture recorded for %s %s", request.Method, request.URL)
+	} else if err != nil {
+		return nil, err
+	}
+
+	return http.ReadResponse(bufio.NewReader(bytes.NewReader(dump)), request)
+}
+
+// Gets the path of the fixture for the given request. A range request gets its
+// own fixture, so that it doesn't collide with a request for the whole file.
+// Other requests keep the key they had before ranges were included.
+func fixturePath(dir string, request *http.Request) string {
+	key := request.Method + " " + request.URL.String()
+	if byteRange := request.Header.Get("Range"); byteRange != "" {
+		key += "\nRange: " + byteRange
+	}
+
+	hash := sha1.Sum([]byte(key))
+	return filepath.Join(dir, fmt.Sprintf("%x.http", hash))
+}
+
+// Returns a client which uses the given transport, or the default client if there isn't one.
+func clientFor(transport http.RoundTripper) *http.Client {
+	if transport == nil {
+		return http.DefaultClient
+	}
+
+	return &http.Client{Transport: transport}
+}
@@ -0,0 +1,112 @@
+package insidescraper
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fixtures")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	server := newFakeSite()
+
+	recordOptions := fakeSiteOptions(server)
+	recordOptions.Transport = &RecordingTransport{Dir: dir}
+	recorder := InsideScraper{Options: recordOptions}
+	if _, err := recorder.Scrape(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Make sure that nothing can come from the network.
+	server.Close()
+
+	replayOptions := fakeSiteOptions(server)
+	replayOptions.Transport = &ReplayTransport{Dir: dir}
+	replayer := InsideScraper{Options: replayOptions}
+	if _, err := replayer.Scrape(); err != nil {
+		t.Fatal(err)
+	}
+
+	recorded, replayed := sectionIDs(recorder.Site), sectionIDs(replayer.Site)
+	if len(recorded) != 4 || len(recorded) != len(replayed) {
+		t.Fatalf("Expected 4 sections in both scrapes, got %v and %v", recorded, replayed)
+	}
+
+	for i := range recorded {
+		if recorded[i] != replayed[i] {
+			t.Errorf("Recorded %s, but replayed %s", recorded[i], replayed[i])
+		}
+	}
+
+	for id := range recorder.Site.Lessons {
+		if _, exists := replayer.Site.Lessons[id]; !exists {
+			t.Errorf("Lesson %s was recorded, but not replayed", id)
+		}
+	}
+}
+
+func TestReplayMissingFixture(t *testing.T) {
+	client := clientFor(&ReplayTransport{Dir: os.TempDir()})
+
+	if _, err := client.Get("http://example.com/not-recorded"); err == nil {
+		t.Error("Expected an error for a request which wasn't recorded")
+	}
+}
+
+func sectionIDs(site Site) []string {
+	ids := make([]string, 0, len(site.Sections))
+	for id := range site.Sections {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestReplayRangeRequests(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fixtures")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "class.mp3", time.Time{}, strings.NewReader("0123456789"))
+	}))
+
+	get := func(client *http.Client, byteRange string) string {
+		request, _ := http.NewRequest("GET", server.URL+"/class.mp3", nil)
+		if byteRange != "" {
+			request.Header.Set("Range", byteRange)
+		}
+		response, err := client.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer response.Body.Close()
+		body, _ := ioutil.ReadAll(response.Body)
+		return string(body)
+	}
+
+	recorder := clientFor(&RecordingTransport{Dir: dir})
+	get(recorder, "bytes=0-3")
+	get(recorder, "")
+	server.Close()
+
+	replayer := clientFor(&ReplayTransport{Dir: dir})
+	if body := get(replayer, "bytes=0-3"); body != "0123" {
+		t.Errorf("Expected the range to be replayed, got %q", body)
+	}
+	if body := get(replayer, ""); body != "0123456789" {
+		t.Errorf("Expected the whole file to be replayed, got %q", body)
+	}
+}
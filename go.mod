@@ -14,4 +14,5 @@ require (
 	github.com/temoto/robotstxt v0.0.0-20180810133444-97ee4a9ee6ea // indirect
 	golang.org/x/net v0.0.0-20190620200207-3b0461eec859 // indirect
 	google.golang.org/appengine v1.6.1 // indirect
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -0,0 +1,90 @@
+package insidescraper
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// DownloadImages downloads the image of every top level section to the given
+// directory, and sets the ImageFile of each one. Files are named by the hash of
+// their content, so an app which bundles them can tell when they change.
+// Images which couldn't be downloaded are returned as problems.
+func DownloadImages(site *Site, dir string, transport http.RoundTripper) []Problem {
+	var problems []Problem
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return []Problem{{Kind: ImageDownloadError, URL: dir, Message: err.Error()}}
+	}
+
+	// The same image can be used for a few sections.
+	downloaded := make(map[string]string, len(site.TopLevel))
+
+	for i, item := range site.TopLevel {
+		if item.Image == "" {
+			continue
+		}
+
+		fileName, exists := downloaded[item.Image]
+		if !exists {
+			var err error
+			if fileName, err = downloadImage(item.Image, dir, clientFor(transport)); err != nil {
+				problems = append(problems, Problem{
+					Kind:    ImageDownloadError,
+					URL:     item.Image,
+					Parent:  item.ID,
+					Message: err.Error(),
+				})
+				continue
+			}
+			downloaded[item.Image] = fileName
+		}
+
+		site.TopLevel[i].ImageFile = fileName
+	}
+
+	return problems
+}
+
+// downloadImage saves the image to the directory, and returns its file name.
+func downloadImage(imageURL, dir string, client *http.Client) (string, error) {
+	response, err := client.Get(imageURL)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s: %s", imageURL, response.Status)
+	}
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return "", err
+	}
+
+	fileName := fmt.Sprintf("%x%s", sha256.Sum256(body), getImageExtension(imageURL, response.Header.Get("Content-Type")))
+
+	return fileName, ioutil.WriteFile(filepath.Join(dir, fileName), body, 0644)
+}
+
+// Gets the file extension of the image, from its URL or else its content type.
+func getImageExtension(imageURL, contentType string) string {
+	if parsed, err := url.Parse(imageURL); err == nil {
+		if extension := path.Ext(parsed.Path); extension != "" {
+			return extension
+		}
+	}
+
+	if extensions, err := mime.ExtensionsByType(contentType); err == nil && len(extensions) > 0 {
+		return extensions[0]
+	}
+
+	return ""
+}
@@ -0,0 +1,46 @@
+package insidescraper
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTopLevelImages(t *testing.T) {
+	server := newFakeSite()
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "images")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	options := fakeSiteOptions(server)
+	options.FallbackImage = server.URL + "/images/fallback.png"
+	options.ImageDir = dir
+
+	scraper := InsideScraper{Options: options}
+	if _, err := scraper.Scrape(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{server.URL + "/images/a.png", server.URL + "/images/fallback.png"}
+	for i, item := range scraper.Site.TopLevel {
+		if item.Image != expected[i] {
+			t.Errorf("Expected image %s, got %s", expected[i], item.Image)
+		}
+
+		content, err := ioutil.ReadFile(filepath.Join(dir, item.ImageFile))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if fileName := fmt.Sprintf("%x.png", sha256.Sum256(content)); fileName != item.ImageFile {
+			t.Errorf("Expected the image to be named by its hash, %s, got %s", fileName, item.ImageFile)
+		}
+	}
+}
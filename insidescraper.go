@@ -2,22 +2,46 @@ package insidescraper
 
 import (
 	"errors"
-	"fmt"
-	"math/rand"
-	"net/http"
+	"net/url"
 	"os"
-	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/gocolly/colly"
 )
 
 // InsideScraper scrapes insidechassidus for lesson structure.
+//
+// Scraping happens in two steps. First every page is visited, possibly in
+// parallel, and what was found on it is recorded. Each visit carries the
+// section it's for in its request context. Then the site is put together from
+// those records, depth first from the start page, so that it comes out the same
+// no matter what order the pages were visited in.
 type InsideScraper struct {
-	activeSection string
-	Site          Site
-	collector     *colly.Collector
+	// Options configures the scrape. Unset options use their defaults.
+	Options   ScraperOptions
+	Site      Site
+	collector *colly.Collector
+	report    *ScrapeReport
+	startURL  string
+	// rootID is the ID of the section which the start page is for. It's empty
+	// for the home page, which isn't a section.
+	rootID string
+	// pages holds what was found on each page, by the URL it was requested with.
+	pages map[string][]pageItem
+	// queued are the pages which were requested but not finished, and finished
+	// are the ones which were.
+	queued   map[string]QueuedPage
+	finished map[string]bool
+	// problems holds the problems which were found on each page, by the URL
+	// it was requested with, so that they're saved with the page.
+	problems map[string][]Problem
+	lock     sync.Mutex
+
+	lastCheckpoint time.Time
+	checkpointLock sync.Mutex
 	// If a section ends up being a lesson (ie it only has lessons, of < 2 audio each)
 	// keep track of it.
 	// Maps the original secion id to the lesson id, so that further references to the section
@@ -25,11 +49,27 @@ type InsideScraper struct {
 	//sectionLessons map[string]string
 }
 
-// Scrape scrapes the site. It returns an error if there's an error.
-func (scraper *InsideScraper) Scrape(scrapeURL ...string) (err error) {
-	scraper.Site.Sections = make(map[string]SiteSection, 1000)
-	scraper.Site.Lessons = make(map[string]Lesson, 1000)
-	scraper.Site.TopLevel = make([]TopItem, 0, 10)
+// Keys of the values in a visit's request context.
+const (
+	// The URL the page was requested with.
+	pageKey = "page"
+	// The ID of the section the page is for. Empty for the home page.
+	sectionKey = "section"
+	// The ID of the section which linked to the page.
+	parentKey = "parent"
+)
+
+// Scrape scrapes the site. It returns a report of every problem which was
+// found on the way, and an error if the scrape couldn't be done.
+func (scraper *InsideScraper) Scrape(scrapeURL ...string) (report *ScrapeReport, err error) {
+	scraper.Options = scraper.Options.withDefaults()
+	scraper.report = &ScrapeReport{strict: scraper.Options.Strict}
+	scraper.pages = make(map[string][]pageItem, 1000)
+	scraper.queued = make(map[string]QueuedPage, 100)
+	scraper.finished = make(map[string]bool, 1000)
+	scraper.problems = make(map[string][]Problem, 100)
+	scraper.rootID = ""
+	scraper.lastCheckpoint = time.Now()
 	//scraper.sectionLessons = make(map[string]string)
 
 	// defer func() {
@@ -46,58 +86,73 @@ func (scraper *InsideScraper) Scrape(scrapeURL ...string) (err error) {
 	// }()
 
 	scraper.collector = colly.NewCollector(
-		colly.UserAgent("inside-scraper"),
-		colly.AllowedDomains("insidechassidus.org"),
+		colly.UserAgent(scraper.Options.UserAgent),
+		colly.AllowedDomains(scraper.Options.AllowedDomains...),
+		colly.Async(scraper.Options.Parallelism > 1),
 	)
 
+	if scraper.Options.Parallelism > 1 {
+		scraper.collector.Limit(&colly.LimitRule{
+			DomainGlob:  "*",
+			Parallelism: scraper.Options.Parallelism,
+		})
+	}
+
+	if scraper.Options.Transport != nil {
+		scraper.collector.WithTransport(scraper.Options.Transport)
+	}
+
+	// In strict mode, nothing more is visited after the first problem.
+	scraper.collector.OnRequest(func(r *colly.Request) {
+		if scraper.report.stopped() {
+			r.Abort()
+		}
+	})
+
 	scraper.collector.OnError(func(r *colly.Response, err error) {
-		fmt.Fprintln(os.Stderr, "Scrape error: "+err.Error())
-		fmt.Fprintln(os.Stderr, "(Possibly) related Url: ", scraper.activeSection+"\n")
+		scraper.report.add(Problem{
+			Kind:    HTTPError,
+			URL:     r.Request.URL.String(),
+			Parent:  r.Ctx.Get(parentKey),
+			Message: err.Error(),
+		})
+	})
+
+	// Scrape the metadata and breadcrumbs of every page.
+	scraper.collector.OnHTML("html", func(e *colly.HTMLElement) {
+		scraper.addItem(e, pageItem{
+			Kind: pageInfoItem,
+			Page: scraper.getSectionPage(e),
+		})
 	})
 
 	// Scrape the top level sections.
-	scraper.collector.OnHTML("body.home #main-menu-fst > li > a ", func(e *colly.HTMLElement) {
-		sectionURL := getFinalURL(e.Attr("href"))
+	scraper.collector.OnHTML(scraper.Options.Profile.MainMenu, func(e *colly.HTMLElement) {
+		sectionURL := scraper.getFinalURL(e.Attr("href"), "", e.Request.Ctx.Get(pageKey))
 		sectionID := getHash(sectionURL)
 
-		// If a top level section was already scraped as a sub section, simply
-		// mark it as being a top level section.
-		if _, exists := scraper.Site.Sections[sectionID]; exists {
-			scraper.Site.TopLevel = append(scraper.Site.TopLevel, TopItem{
-				ID: sectionID,
-			})
-			return
-		}
-
-		scraper.Site.Sections[sectionID] = SiteSection{
-			SiteData: &SiteData{
-				Title: e.Text,
-			},
-			ID:       sectionID,
-			Sections: make([]string, 0, 10),
-		}
-		scraper.Site.TopLevel = append(scraper.Site.TopLevel, TopItem{
-			ID: sectionID,
+		scraper.addItem(e, pageItem{
+			Kind:  topLevelItem,
+			URL:   sectionID,
+			Title: e.Text,
+			Image: scraper.getMenuImage(e),
 		})
 
-		scraper.activeSection = sectionID
-		err := scraper.collector.Visit(sectionURL)
-
-		if err != nil {
-			fmt.Fprintln(os.Stderr, "Visit main section error (", sectionID, "):", err.Error()+"\n")
-		}
+		scraper.visit(sectionURL, sectionID, "")
 	})
 
 	// Scrape lessons and sub sections.
-	scraper.collector.OnHTML("tbody tr", func(e *colly.HTMLElement) {
+	scraper.collector.OnHTML(scraper.Options.Profile.Row, func(e *colly.HTMLElement) {
 		domParent := e.DOM
+		sectionID := e.Request.Ctx.Get(sectionKey)
 
-		firstColumn := domParent.Find("td:nth-child(1)")
-		secondColumn := domParent.Find("td:nth-child(2)")
-		thirdColumn := domParent.Find("td:nth-child(3)")
+		profile := scraper.Options.Profile
+		firstColumn := domParent.Find(profile.TitleColumn)
+		secondColumn := domParent.Find(profile.MediaColumn)
+		thirdColumn := domParent.Find(profile.DescriptionColumn)
 
 		// If there's no media in the second column, then it must be a section.
-		if secondColumn.Find("[mp3],a[href$=\".pdf\"]").Length() == 0 {
+		if secondColumn.Find(profile.RowMedia).Length() == 0 && secondColumn.Find(profile.RowVideo).Length() == 0 {
 			// Note that sometimes (Eg Rebbetzin Shaindle https://insidechassidus.org/thought-and-history/23-lives-of-the-chabad-rebbeim)
 			// a section is shown as a lesson without media, so the columns are title | (blank) | description.
 			// If that's the case, use the 3rd column as the description.
@@ -106,233 +161,328 @@ func (scraper *InsideScraper) Scrape(scrapeURL ...string) (err error) {
 			if descriptionColumn.Length() == 0 {
 				descriptionColumn = secondColumn
 			}
-			scraper.loadSection(firstColumn, descriptionColumn)
+
+			item := scraper.loadSection(e, firstColumn, descriptionColumn, sectionID)
+			scraper.addItem(e, item)
+
+			if item.visitsSection() {
+				scraper.visit(item.URL, getHash(item.URL), sectionID)
+			}
 		} else if thirdColumn.Length() != 0 {
-			scraper.loadLessons(domParent)
+			scraper.addItem(e, pageItem{
+				Kind:   lessonItem,
+				Lesson: scraper.loadLesson(e, sectionID),
+			})
 		} else {
-			text, _ := domParent.Html()
-			fmt.Fprintln(os.Stderr, "Error: could not process row", text)
+			scraper.addProblem(e.Request.Ctx.Get(pageKey), Problem{
+				Kind:    UnparseableRow,
+				URL:     e.Request.Ctx.Get(pageKey),
+				Parent:  sectionID,
+				Excerpt: excerpt(domParent),
+				Message: "Could not process row",
+			})
 		}
 	})
 
 	// Scrape lessons which aren't in a table
-	scraper.collector.OnHTML("div > div > a[mp3]", func(e *colly.HTMLElement) {
-		if isOnMobile(e.DOM) {
+	scraper.collector.OnHTML(scraper.Options.Profile.Lesson, func(e *colly.HTMLElement) {
+		if scraper.isOnMobile(e.DOM) {
 			return
 		}
 
+		profile := scraper.Options.Profile
 		parent := e.DOM.Parent()
-		title := strings.TrimSpace(parent.Find("h1").Text())
-		description := strings.TrimSpace(parent.Find("div").Text())
-		mp3, _ := parent.Find("a[mp3]").Attr("mp3")
+		title := strings.TrimSpace(parent.Find(profile.LessonTitle).Text())
+		domDescription := parent.Find(profile.LessonDescription)
+		description := strings.TrimSpace(domDescription.Text())
+		mp3, _ := parent.Find(profile.audioLink()).Attr(profile.AudioAttribute)
 
 		newLesson := Lesson{
-			ID: strconv.Itoa(rand.Int()),
 			SiteData: &SiteData{
-				Title:       title,
-				Description: description,
+				Title:           title,
+				Description:     description,
+				DescriptionHTML: sanitizeHTML(domDescription, e.Request.URL.String()),
 			},
 			Audio: []Media{Media{
-				Source: mp3,
+				SiteData: &SiteData{},
+				Source:   mp3,
 			},
 			},
 		}
+		setOrdinals(&newLesson)
+		newLesson.ID = MakeLessonID(e.Request.Ctx.Get(sectionKey), e.Index, &newLesson)
 
-		scraper.Site.Lessons[newLesson.ID] = newLesson
-
-		activeSection, _ := scraper.Site.Sections[scraper.activeSection]
-		activeSection.Lessons = append(activeSection.Lessons, newLesson.ID)
-		scraper.Site.Sections[scraper.activeSection] = activeSection
+		scraper.addItem(e, pageItem{
+			Kind:   lessonItem,
+			Lesson: &newLesson,
+		})
 	})
 
 	// Scrape pdfs which are for a given section.
-	// This should get run at start of the section's visit.
-	scraper.collector.OnHTML("div > div > a[href]", func(e *colly.HTMLElement) {
-		if isOnMobile(e.DOM) {
+	scraper.collector.OnHTML(scraper.Options.Profile.SectionPdf, func(e *colly.HTMLElement) {
+		if scraper.isOnMobile(e.DOM) {
 			return
 		}
 
-		pdfURL := e.Attr("href")
-
-		if !strings.HasSuffix(pdfURL, ".pdf") {
+		if !strings.HasSuffix(e.Attr("href"), ".pdf") {
 			return
 		}
 
-		if scraper.activeSection == "" {
-			fmt.Println("Trying to load PDF, no active section...")
+		pdfURL := e.Request.AbsoluteURL(e.Attr("href"))
+
+		if e.Request.Ctx.Get(sectionKey) == "" {
+			scraper.addProblem(e.Request.Ctx.Get(pageKey), Problem{
+				Kind:    PdfWithoutSection,
+				URL:     pdfURL,
+				Excerpt: excerpt(e.DOM),
+				Message: "Trying to load PDF, no active section",
+			})
 		} else {
-			section := scraper.Site.Sections[scraper.activeSection]
-			section.Pdf = append(section.Pdf, pdfURL)
-			scraper.Site.Sections[scraper.activeSection] = section
+			scraper.addItem(e, pageItem{
+				Kind:  pdfItem,
+				URL:   pdfURL,
+				Title: strings.TrimSpace(e.Text),
+			})
 		}
 	})
 
-	source := "https://insidechassidus.org/"
-	if len(scrapeURL) == 1 {
-		source = scrapeURL[0]
-	}
-
-	scraper.collector.Visit(source)
+	// Once a page is finished, everything on it was recorded.
+	scraper.collector.OnScraped(func(r *colly.Response) {
+		page := r.Ctx.Get(pageKey)
 
-	//scraper.applyLessonConversions()
+		scraper.lock.Lock()
+		delete(scraper.queued, page)
+		scraper.finished[page] = true
+		scraper.pages[page] = scraper.pages[page]
+		scraper.lock.Unlock()
 
-	return err
-}
+		scraper.checkpoint(false)
+	})
 
-func (scraper *InsideScraper) loadLessons(dom *goquery.Selection) {
-	lessonScraper := LessonScraper{
-		Row: dom,
+	scraper.startURL = scraper.Options.BaseURL
+	if len(scrapeURL) == 1 {
+		scraper.startURL = scrapeURL[0]
 	}
 
-	lessonScraper.LoadLesson()
-	scraper.Site.Lessons[lessonScraper.Lesson.ID] = *lessonScraper.Lesson
-
-	// Append this lesson id to current section.
-	activeSection, _ := scraper.Site.Sections[scraper.activeSection]
-	activeSection.Lessons = append(activeSection.Lessons, lessonScraper.Lesson.ID)
-	scraper.Site.Sections[scraper.activeSection] = activeSection
-}
-
-func (scraper *InsideScraper) loadSection(firstColumn, domDescription *goquery.Selection) {
-
-	// The name of the section. A link.
-	domName := firstColumn.Find("a")
-
-	sectionURLs := getSectionURLFromHereLink(domDescription)
-
-	sectionTitleURL, err := scraper.getSectionURLFromTitle(firstColumn)
-
-	// Urls in description are references to sections which are in a different section.
-	// Don't scrape them now, just add that reference to the current section.
-
-	// More than 1: create a sub section.
-	if len(sectionURLs) > 1 {
-		subSections := make([]string, 0, len(sectionURLs))
-		for _, url := range sectionURLs {
-			subSections = append(subSections, getHash(url))
-		}
-
-		currentURL, _ := domName.Attr("href")
-		currentURL = getFinalURL(currentURL)
-		currentID := currentURL
+	resumed, err := scraper.resume()
+	if err != nil {
+		return scraper.report, err
+	}
 
-		if _, exists := scraper.Site.Sections[currentID]; exists {
-			panic("Error!!! Section which references other sections already exists!!!\nParent:" +
-				scraper.activeSection + "\nAlready here error cause: " + currentID)
+	if !resumed {
+		// A scrape which starts from a page other than the root of the site
+		// scrapes one branch of it. Its start page is a section, with the ID it
+		// has in a full scrape, so that the branch can be merged into the full site.
+		if !isSiteRoot(scraper.startURL) {
+			scraper.rootID = getHash(scraper.getFinalURL(scraper.startURL, "", scraper.startURL))
 		}
+		scraper.visit(scraper.startURL, scraper.rootID, "")
+	}
+	scraper.collector.Wait()
+
+	// A scrape which was stopped can be resumed. One which finished starts
+	// again from scratch next time, instead of returning the same site.
+	if scraper.report.stopped() {
+		scraper.checkpoint(true)
+	} else {
+		scraper.removeCheckpoint()
+	}
+	scraper.Site = buildSite(scraper.pages, scraper.startURL, scraper.rootID, scraper.report)
+	if scraper.Options.ResolveDescriptionLinks {
+		scraper.Site.LinkDescriptions(allowedURLResolver{scraper})
+	} else {
+		scraper.Site.LinkDescriptions(nil)
+	}
 
-		scraper.Site.Sections[currentID] = SiteSection{
-			SiteData: &SiteData{
-				Title:       strings.TrimSpace(domName.Text()),
-				Description: strings.TrimSpace(domDescription.Text()),
-			},
-			ID:       currentID,
-			Sections: subSections,
+	if scraper.Options.ImageDir != "" {
+		for _, problem := range DownloadImages(&scraper.Site, scraper.Options.ImageDir, scraper.Options.Transport) {
+			scraper.report.add(problem)
 		}
+	}
 
-		activeSection := scraper.Site.Sections[scraper.activeSection]
-		activeSection.Sections = append(activeSection.Sections, currentID)
-		scraper.Site.Sections[scraper.activeSection] = activeSection
+	//scraper.applyLessonConversions()
 
-		return
+	if scraper.report.stopped() {
+		err = *scraper.report.first
 	}
 
-	// If there's only 1 referenced: Add it to current section.
-	// If description has same URL as title, then this is a good link and we should follow it.
-	if len(sectionURLs) == 1 && sectionURLs[0] != sectionTitleURL {
-		activeSection := scraper.Site.Sections[scraper.activeSection]
-		activeSection.Sections = append(activeSection.Sections, getHash(sectionURLs[0]))
-		scraper.Site.Sections[scraper.activeSection] = activeSection
+	scraper.report.sort()
+	return scraper.report, err
+}
 
+// visit requests the page of the given section.
+func (scraper *InsideScraper) visit(url, sectionID, parentID string) {
+	// A page which is referenced from a few places is only visited once.
+	scraper.lock.Lock()
+	_, isQueued := scraper.queued[url]
+	if isQueued || scraper.finished[url] {
+		scraper.lock.Unlock()
 		return
 	}
+	scraper.queued[url] = QueuedPage{
+		URL:     url,
+		Section: sectionID,
+		Parent:  parentID,
+	}
+	scraper.lock.Unlock()
+
+	ctx := colly.NewContext()
+	ctx.Put(pageKey, url)
+	ctx.Put(sectionKey, sectionID)
+	ctx.Put(parentKey, parentID)
+
+	err := scraper.collector.Request("GET", url, nil, ctx, nil)
+
+	// Errors from making the request are reported by OnError. Only report
+	// requests which weren't made at all.
+	if isNotRequestedError(err) {
+		scraper.report.add(Problem{
+			Kind:    HTTPError,
+			URL:     url,
+			Parent:  parentID,
+			Message: err.Error(),
+		})
+	}
+}
 
-	if err != nil {
-		fmt.Fprintln(os.Stderr, err.Error()+"\n")
-		return
+// resume continues from the last checkpoint, if the options ask to and there
+// is one. It returns whether the scrape was resumed.
+func (scraper *InsideScraper) resume() (bool, error) {
+	if !scraper.Options.Resume || scraper.Options.CheckpointPath == "" {
+		return false, nil
 	}
 
-	if sectionTitleURL == "" {
-		fmt.Fprintln(os.Stderr, "Error: URL not found. Parent: "+scraper.activeSection)
-		return
+	checkpoint, err := ReadCheckpoint(scraper.Options.CheckpointPath)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
 	}
 
-	sectionID := getHash(sectionTitleURL)
+	scraper.startURL = checkpoint.StartURL
+	scraper.rootID = checkpoint.RootID
+	for url, items := range checkpoint.Pages {
+		scraper.pages[url] = items
+		scraper.finished[url] = true
+	}
+	for url, problems := range checkpoint.Problems {
+		for _, problem := range problems {
+			scraper.addProblem(url, problem)
+		}
+	}
 
-	// Add this section to the parent section.
-	if scraper.activeSection != "" {
-		activeSection, _ := scraper.Site.Sections[scraper.activeSection]
-		activeSection.Sections = append(activeSection.Sections, sectionID)
-		scraper.Site.Sections[scraper.activeSection] = activeSection
+	for _, page := range checkpoint.Queued {
+		scraper.visit(page.URL, page.Section, page.Parent)
 	}
 
-	// If a section is referenced in multiple places and it was already visited,
-	// don't try to create it again; it'll end up making an empty section (because it's URL has
-	// already been scraped), and over-writing the real data.
-	if _, hasKey := scraper.Site.Sections[sectionID]; hasKey {
-		return
+	return true, nil
+}
+
+// addProblem reports a problem which was found on the given page, which is the
+// URL it was requested with.
+func (scraper *InsideScraper) addProblem(page string, problem Problem) {
+	scraper.report.add(problem)
+
+	scraper.lock.Lock()
+	scraper.problems[page] = append(scraper.problems[page], problem)
+	scraper.lock.Unlock()
+}
+
+// addItem records something which was found on the element's page.
+func (scraper *InsideScraper) addItem(e *colly.HTMLElement, item pageItem) {
+	page := e.Request.Ctx.Get(pageKey)
+
+	scraper.lock.Lock()
+	scraper.pages[page] = append(scraper.pages[page], item)
+	scraper.lock.Unlock()
+}
+
+// loadLesson reads a row which is a lesson, in the given section.
+func (scraper *InsideScraper) loadLesson(e *colly.HTMLElement, sectionID string) *Lesson {
+	lessonScraper := LessonScraper{
+		Row:       e.DOM,
+		PageURL:   e.Request.URL.String(),
+		Profile:   scraper.Options.Profile,
+		SectionID: sectionID,
+		Position:  e.Index,
+		SortAudio: scraper.Options.SortAudio,
 	}
 
-	/*
-		Load a section and all of it's children.
-	*/
-
-	newSection := SiteSection{
-		SiteData: &SiteData{
-			Title:       strings.TrimSpace(domName.Text()),
-			Description: strings.TrimSpace(domDescription.Text()),
-		},
-		ID:       sectionID,
-		Sections: make([]string, 0, 20),
-		Lessons:  make([]string, 0, 20),
+	lessonScraper.LoadLesson()
+
+	for _, problem := range lessonScraper.Problems {
+		problem.Parent = sectionID
+		scraper.addProblem(e.Request.Ctx.Get(pageKey), problem)
 	}
 
-	scraper.Site.Sections[sectionID] = newSection
+	return lessonScraper.Lesson
+}
 
-	// Back up current section, restore it as active after finished with this section.
-	parentOfNewSection := scraper.activeSection
-	scraper.activeSection = sectionID
-	scraper.collector.Visit(sectionTitleURL)
+// loadSection reads a row which is a section. parentID is the section the row
+// is in.
+func (scraper *InsideScraper) loadSection(e *colly.HTMLElement, firstColumn, domDescription *goquery.Selection, parentID string) pageItem {
+	// The name of the section. A link.
+	domName := firstColumn.Find("a")
+	pageURL := e.Request.URL.String()
+	page := e.Request.Ctx.Get(pageKey)
+
+	item := pageItem{
+		Kind:            sectionItem,
+		Title:           strings.TrimSpace(domName.Text()),
+		Description:     strings.TrimSpace(domDescription.Text()),
+		DescriptionHTML: sanitizeHTML(domDescription, pageURL),
+		HereURLs:        scraper.getSectionURLFromHereLink(domDescription, parentID, page),
+	}
 
-	// // If this section is really a lesson, save that fact for later use.
-	// if err := scraper.Site.ConvertToLesson(sectionID); err == nil {
-	// 	scraper.sectionLessons[sectionID] = scraper.Site.Lessons[sectionID].ID
-	// }
+	sectionTitleURL, err := scraper.getSectionURLFromTitle(firstColumn, parentID, page)
+	item.URL = sectionTitleURL
+	if err != nil {
+		item.URLError = err.Error()
+		item.Excerpt = excerpt(firstColumn)
+	}
 
-	scraper.activeSection = parentOfNewSection
+	return item
 }
 
 // Some sections have the correct URL to its contents in a here link in the description.
-func getSectionURLFromHereLink(domDescription *goquery.Selection) []string {
+func (scraper *InsideScraper) getSectionURLFromHereLink(domDescription *goquery.Selection, parentID, page string) []string {
 	hereLink := domDescription.Find("a").FilterFunction(func(i int, selection *goquery.Selection) bool {
 		url, _ := selection.Attr("href")
 
-		return strings.Contains(selection.Text(), "here") && strings.Contains(url, "insidechassidus.org")
+		return strings.Contains(selection.Text(), scraper.Options.Profile.HereLinkText) && scraper.isAllowedURL(url)
 	})
 
 	if hereLink.Length() == 0 {
 		return nil
 	}
 
-	return hereLink.Map(func(_ int, selection *goquery.Selection) string {
+	urls := make([]string, 0, hereLink.Length())
+	hereLink.Each(func(_ int, selection *goquery.Selection) {
 		if url, exists := selection.Attr("href"); exists {
-			return getFinalURL(url)
+			urls = append(urls, scraper.getFinalURL(url, parentID, page))
+			return
 		}
 
-		panic("Hey! No url")
+		scraper.addProblem(page, Problem{
+			Kind:    MissingHereURL,
+			Parent:  parentID,
+			Excerpt: excerpt(selection),
+			Message: "Here link has no URL",
+		})
 	})
+
+	return urls
 }
 
 // Most sections have the URL to contents in the title (which is a link).
-func (scraper *InsideScraper) getSectionURLFromTitle(firstColumn *goquery.Selection) (string, error) {
+func (scraper *InsideScraper) getSectionURLFromTitle(firstColumn *goquery.Selection, parentID, page string) (string, error) {
 
 	sectionURL, exists := firstColumn.Find("a").Attr("href")
 	if !exists {
-		childHTML, _ := firstColumn.Html()
-		return "", errors.New("No href!\nParent: " + scraper.activeSection + "\nchild:\n" + childHTML)
+		return "", errors.New("No href")
 	}
 
-	return getFinalURL(sectionURL), nil
+	return scraper.getFinalURL(sectionURL, parentID, page), nil
 }
 
 // If a section was converted to a lesson, there may be references to that section.
@@ -359,23 +509,94 @@ func (scraper *InsideScraper) getSectionURLFromTitle(firstColumn *goquery.Select
 // 	}
 // }
 
-// Get's the URL after all redirects.
-func getFinalURL(url string) string {
-	response, err := http.Head(url)
+// Get's the URL after all redirects. If it can't be found, the URL is used as it is.
+// page is the page the link is on.
+func (scraper *InsideScraper) getFinalURL(url, parentID, page string) string {
+	finalURL, err := scraper.Options.URLResolver.Resolve(url)
 	if err == nil {
-		return response.Request.URL.String()
+		return finalURL
 	}
 
-	fmt.Fprintln(os.Stderr, "Error: failed HEAD request ("+url+")\nError: "+err.Error()+"\n")
+	scraper.addProblem(page, Problem{
+		Kind:    FailedHead,
+		URL:     url,
+		Parent:  parentID,
+		Message: err.Error(),
+	})
 	return url
 }
 
+// isNotRequestedError checks if the error is why colly decided not to make a request.
+func isNotRequestedError(err error) bool {
+	switch err {
+	case colly.ErrForbiddenDomain, colly.ErrForbiddenURL, colly.ErrMissingURL,
+		colly.ErrNoURLFiltersMatch, colly.ErrMaxDepth, colly.ErrRobotsTxtBlocked:
+		return true
+	}
+
+	return false
+}
+
+// isSiteRoot checks if the URL is of the home page of its site.
+func isSiteRoot(pageURL string) bool {
+	parsed, err := url.Parse(pageURL)
+	if err != nil {
+		return false
+	}
+	return parsed.Path == "" || parsed.Path == "/"
+}
+
 func getHash(source string) string {
 	//	idBytes := md5.Sum([]byte(source))
 	//	return fmt.Sprintf("%x", idBytes)
 	return source
 }
 
-func isOnMobile(dom *goquery.Selection) bool {
-	return dom.Closest(".visible-xs").Length() != 0
+// getMenuImage finds the absolute URL of the image of a main menu entry, or
+// the fallback image if it doesn't have one.
+func (scraper *InsideScraper) getMenuImage(e *colly.HTMLElement) string {
+	selector := scraper.Options.Profile.MainMenuImage
+
+	image := e.DOM.Find(selector)
+	if image.Length() == 0 {
+		image = e.DOM.Parent().Find(selector)
+	}
+
+	source, exists := image.Attr("src")
+	if !exists {
+		source, exists = image.Attr("data-src")
+	}
+	if !exists || source == "" {
+		return scraper.Options.FallbackImage
+	}
+
+	return e.Request.AbsoluteURL(source)
+}
+
+// Checks if the URL is on one of the allowed domains.
+func (scraper *InsideScraper) isAllowedURL(url string) bool {
+	for _, domain := range scraper.Options.AllowedDomains {
+		if strings.Contains(url, domain) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// allowedURLResolver resolves only the URLs which may be scraped; any other
+// URL can't be of a section.
+type allowedURLResolver struct {
+	scraper *InsideScraper
+}
+
+func (resolver allowedURLResolver) Resolve(url string) (string, error) {
+	if !resolver.scraper.isAllowedURL(url) {
+		return url, nil
+	}
+	return resolver.scraper.Options.URLResolver.Resolve(url)
+}
+
+func (scraper *InsideScraper) isOnMobile(dom *goquery.Selection) bool {
+	return dom.Closest(scraper.Options.Profile.Mobile).Length() != 0
 }
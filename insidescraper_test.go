@@ -3,8 +3,12 @@ package insidescraper
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -130,12 +134,16 @@ func TestFullRun(t *testing.T) {
 func runScraper(scraperURL ...string) {
 	scraper := InsideScraper{}
 
-	if err := scraper.Scrape(scraperURL...); err != nil {
+	report, err := scraper.Scrape(scraperURL...)
+	if err != nil {
 		fmt.Println("Error in scrape: " + err.Error())
 	}
 
 	output, _ := json.MarshalIndent(scraper.Site, "", "    ")
 	fmt.Println("Site data:\n\n", string(output))
+
+	output, _ = json.MarshalIndent(report, "", "    ")
+	fmt.Println("Problems:\n\n", string(output))
 }
 
 func printNotFixed(corrections map[string]Correction) {
@@ -188,3 +196,190 @@ func printCorrections(corrections map[string]Correction) {
 		fmt.Print("\n")
 	}
 }
+
+// fakeSitePages is a tiny copy of the layout of insidechassidus.
+// "{{site}}" is replaced with the URL of the test server.
+var fakeSitePages = map[string]string{
+	"/": `<html><body class="home"><ul id="main-menu-fst">
+		<li><a href="{{site}}/section-a"><img src="/images/a.png">Section A</a></li>
+		<li><a href="{{site}}/b">Section B</a></li>
+	</ul></body></html>`,
+	"/section-a": `<html><body><table><tbody>
+		<tr><td>Lesson One</td><td><a mp3="{{site}}/one.mp3">MP3</a></td><td>The first lesson</td></tr>
+		<tr><td><a href="{{site}}/section-a/sub">Sub Section</a></td><td>A sub section. <b>See</b> <a href="/b">section B</a></td></tr>
+	</tbody></table></body></html>`,
+	"/section-a/sub": `<html><head>
+		<title>Sub Section | Fake Site</title>
+		<link rel="canonical" href="/section-a/sub">
+		<meta property="og:title" content="Sub Section">
+		<meta property="og:image" content="{{site}}/images/sub.png">
+		</head><body>
+		<ul class="breadcrumb"><li><a href="/">Home</a></li><li><a href="/section-a">Section A</a></li><li>Sub Section</li></ul>
+		<table><tbody>
+		<tr><td>Class One</td><td>Class One <a mp3="{{site}}/sub-1.mp3">MP3</a><br>Class Two <a mp3="{{site}}/sub-2.mp3">MP3</a></td><td>Class One
+			The first class
+			Class Two
+			The second class</td></tr>
+	</tbody></table></body></html>`,
+	"/section-b": `<html><body>
+		<div><div><a href="/section-b.pdf">PDF</a></div></div>
+		<div><div><h1>Single Lesson</h1><a mp3="{{site}}/single.mp3">MP3</a><div>A lesson without a table</div></div></div>
+		<table><tbody>
+		<tr><td><a href="{{site}}/section-a/sub">Sub Section</a></td><td>Shared with section A</td></tr>
+		<tr><td><a href="{{site}}/missing">Missing Section</a></td><td>A link to nowhere</td></tr>
+		<tr><td>Broken Lesson</td><td><a mp3="{{site}}/broken.mp3">MP3</a></td></tr>
+		<tr><td>Unlinked Section</td><td>A section without a link</td></tr>
+		</tbody></table></body></html>`,
+}
+
+// fakeSiteRedirects are the old URLs of pages on the fake site.
+var fakeSiteRedirects = map[string]string{
+	"/b": "/section-b",
+}
+
+// newFakeSite serves fakeSitePages.
+func newFakeSite() *httptest.Server {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if target, exists := fakeSiteRedirects[r.URL.Path]; exists {
+			http.Redirect(w, r, target, http.StatusMovedPermanently)
+			return
+		}
+
+		if strings.HasPrefix(r.URL.Path, "/images/") {
+			w.Header().Set("Content-Type", "image/png")
+			io.WriteString(w, "fake image of "+r.URL.Path)
+			return
+		}
+
+		page, exists := fakeSitePages[r.URL.Path]
+		if !exists {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html")
+		io.WriteString(w, strings.Replace(page, "{{site}}", server.URL, -1))
+	}))
+
+	return server
+}
+
+// fakeSiteOptions returns options to scrape the fake site.
+func fakeSiteOptions(server *httptest.Server) ScraperOptions {
+	return ScraperOptions{
+		BaseURL:        server.URL + "/",
+		AllowedDomains: []string{strings.TrimPrefix(server.URL, "http://")},
+	}
+}
+
+func TestScrapeOptions(t *testing.T) {
+	server := newFakeSite()
+	defer server.Close()
+
+	scraper := InsideScraper{
+		Options: fakeSiteOptions(server),
+	}
+	if _, err := scraper.Scrape(); err != nil {
+		t.Fatal(err)
+	}
+
+	site := scraper.Site
+
+	if len(site.TopLevel) != 2 {
+		t.Fatalf("Expected 2 top level sections, got %d", len(site.TopLevel))
+	}
+
+	sectionA := site.Sections[server.URL+"/section-a"]
+	if len(sectionA.Lessons) != 1 || len(sectionA.Sections) != 1 {
+		t.Errorf("Section A: expected 1 lesson and 1 section, got %d and %d", len(sectionA.Lessons), len(sectionA.Sections))
+	}
+
+	sub := site.Sections[server.URL+"/section-a/sub"]
+	if len(sub.Lessons) != 1 || len(site.Lessons[sub.Lessons[0]].Audio) != 2 {
+		t.Errorf("Sub section: expected 1 lesson with 2 classes, got %+v", sub)
+	}
+
+	sectionB := site.Sections[server.URL+"/section-b"]
+	if len(sectionB.Pdf) != 1 || len(sectionB.Lessons) != 1 || len(sectionB.Sections) != 2 {
+		t.Errorf("Section B: expected 1 PDF, 1 lesson and 2 sections, got %+v", sectionB)
+	}
+	if pdf := sectionB.Pdf[0]; pdf.URL != server.URL+"/section-b.pdf" || pdf.Title != "PDF" || pdf.Page != server.URL+"/section-b" {
+		t.Errorf("Section B: expected the PDF's absolute URL, title and page, got %+v", pdf)
+	}
+}
+
+func TestScrapeReport(t *testing.T) {
+	server := newFakeSite()
+	defer server.Close()
+
+	scraper := InsideScraper{
+		Options: fakeSiteOptions(server),
+	}
+	report, err := scraper.Scrape()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, kind := range []ProblemKind{HTTPError, UnparseableRow, MissingHref} {
+		if count := report.Count(kind); count != 1 {
+			t.Errorf("Expected 1 %s problem, got %d", kind, count)
+		}
+	}
+
+	if len(report.Problems) != 3 {
+		t.Errorf("Expected 3 problems, got %+v", report.Problems)
+	}
+
+	for _, problem := range report.Problems {
+		if problem.Parent != server.URL+"/section-b" {
+			t.Errorf("Expected %s problem to be in section B, got %s", problem.Kind, problem.Parent)
+		}
+	}
+}
+
+func TestParallelScrapeMatchesSequential(t *testing.T) {
+	server := newFakeSite()
+	defer server.Close()
+
+	scrape := func(parallelism int) string {
+		options := fakeSiteOptions(server)
+		options.Parallelism = parallelism
+
+		scraper := InsideScraper{Options: options}
+		if _, err := scraper.Scrape(); err != nil {
+			t.Fatal(err)
+		}
+
+		jsonOut, _ := json.Marshal(scraper.Site)
+		return string(jsonOut)
+	}
+
+	sequential := scrape(1)
+
+	for i := 0; i < 5; i++ {
+		if parallel := scrape(4); parallel != sequential {
+			t.Fatalf("Parallel scrape:\n%s\ndoesn't match sequential scrape:\n%s", parallel, sequential)
+		}
+	}
+}
+
+func TestStrictScrape(t *testing.T) {
+	server := newFakeSite()
+	defer server.Close()
+
+	options := fakeSiteOptions(server)
+	options.Strict = true
+
+	scraper := InsideScraper{Options: options}
+	report, err := scraper.Scrape()
+
+	problem, isProblem := err.(Problem)
+	if !isProblem {
+		t.Fatalf("Expected the scrape to stop with a problem, got %v", err)
+	}
+
+	if len(report.Problems) == 0 || problem.Parent != server.URL+"/section-b" {
+		t.Errorf("Expected the problem to be in section B, got %+v", problem)
+	}
+}
@@ -24,29 +24,58 @@ func (counter *LessonCounter) CountLessons() {
 	}
 }
 
-func (counter *LessonCounter) countLessons(sectionID string) int {
+// countLessons counts the media in the section, and the total length of its
+// audio. It returns the counted section.
+func (counter *LessonCounter) countLessons(sectionID string) SiteSection {
 	section := counter.Data.Sections[sectionID]
 
 	if isBeingCounted := counter.isCounted[sectionID]; isBeingCounted {
-		return 0
+		return SiteSection{}
 	}
-	if section.AudioCount > 0 {
-		return section.AudioCount
+	if section.AudioCount > 0 || section.VideoCount > 0 {
+		return section
 	}
 	counter.isCounted[sectionID] = true
 
 	counter.Data.Sections[sectionID] = section
 
 	for _, id := range section.Sections {
-		section.AudioCount += counter.countLessons(id)
+		counted := counter.countLessons(id)
+		section.AudioCount += counted.AudioCount
+		section.AudioDuration += counted.AudioDuration
+		section.VideoCount += counted.VideoCount
 	}
 
 	for _, id := range section.Lessons {
-		section.AudioCount += len(counter.Data.Lessons[id].Audio)
+		lesson := counter.Data.Lessons[id]
+		for _, media := range lesson.Audio {
+			section.AudioCount++
+			section.AudioDuration += media.Duration
+		}
+		section.VideoCount += len(lesson.Video)
 	}
 
 	counter.isCounted[sectionID] = false
 	counter.Data.Sections[sectionID] = section
 
-	return section.AudioCount
+	return section
+}
+
+// Hours gets the total length of all the audio classes on the site, in hours.
+// Each class is only counted once, even if it's in a few sections.
+// It's only known if the media was enriched.
+func (counter *LessonCounter) Hours() float64 {
+	counted := make(map[string]bool, len(counter.Data.Lessons))
+	seconds := 0.0
+
+	for _, lesson := range counter.Data.Lessons {
+		for _, media := range lesson.Audio {
+			if !counted[media.Source] {
+				counted[media.Source] = true
+				seconds += media.Duration
+			}
+		}
+	}
+
+	return seconds / 3600
 }
@@ -1,8 +1,9 @@
 package insidescraper
 
 import (
+	"crypto/sha1"
 	"fmt"
-	"math/rand"
+	"net/url"
 	"strconv"
 	"strings"
 	"unicode"
@@ -14,52 +15,176 @@ import (
 type LessonScraper struct {
 	Row    *goquery.Selection
 	Lesson *Lesson
+	// Profile finds the columns of the row. Unset fields use their defaults.
+	Profile SiteProfile
+	// PageURL is the page the row is on. Links are resolved from it.
+	PageURL string
+	// SectionID is the section the row is in, and Position is the row's index on
+	// the page. Together with the media sources they make the lesson ID.
+	SectionID string
+	Position  int
+	// SortAudio sorts the lesson's audio by their ordinals.
+	SortAudio bool
+	// Problems are the problems which were found in the row.
+	Problems []Problem
 }
 
 // LoadLesson scrapes the row and returns a structured lesson.
 func (scraper *LessonScraper) LoadLesson() {
-	title := scraper.Row.ChildrenFiltered("td:first-child").Text()
+	scraper.Profile = scraper.Profile.withDefaults()
+	title := scraper.Row.ChildrenFiltered(scraper.Profile.TitleColumn).Text()
 	scraper.Lesson = &Lesson{
 		SiteData: &SiteData{
 			Title: strings.TrimSpace(title),
 		},
-		ID: strconv.Itoa(rand.Int()),
 	}
 	scraper.loadMediaSources()
 	scraper.loadMediaDescription()
+	setOrdinals(scraper.Lesson)
+	// The ID is made before sorting, so that it doesn't depend on the option.
+	scraper.Lesson.ID = MakeLessonID(scraper.SectionID, scraper.Position, scraper.Lesson)
+
+	if scraper.SortAudio {
+		scraper.Lesson.SortAudio()
+	}
+}
+
+// MakeLessonID makes an ID for a lesson which doesn't have a page of its own.
+// It's derived from where the lesson is and what it contains, so that the same
+// lesson gets the same ID every time the site is scraped.
+func MakeLessonID(sectionID string, position int, lesson *Lesson) string {
+	parts := []string{sectionID, strconv.Itoa(position)}
+
+	for _, audio := range lesson.Audio {
+		parts = append(parts, audio.Source)
+	}
+	for _, pdf := range lesson.Pdf {
+		parts = append(parts, pdf.URL)
+	}
+	// Videos are last, so that the IDs of lessons without them didn't change
+	// when videos were added.
+	for _, video := range lesson.Video {
+		parts = append(parts, video.Source)
+	}
+
+	return fmt.Sprintf("%x", sha1.Sum([]byte(strings.Join(parts, "\n"))))
 }
 
 // Creates the media objects, sets source, and title if available.
 func (scraper *LessonScraper) loadMediaSources() {
-	mediaParent := scraper.Row.ChildrenFiltered("td:nth-child(2)")
+	mediaParent := scraper.Row.ChildrenFiltered(scraper.Profile.MediaColumn)
 	// The media item which is being formed.
 	newMedia := &Media{SiteData: &SiteData{}}
 
 	mediaParent.Contents().Each(func(_ int, s *goquery.Selection) {
+		// Videos are often embedded inside a paragraph etc.
+		if videos := s.Filter(scraper.Profile.RowVideo).AddSelection(s.Find(scraper.Profile.RowVideo)); videos.Length() > 0 {
+			videos.Each(func(_ int, video *goquery.Selection) {
+				scraper.addVideo(video, newMedia.Title)
+			})
+			newMedia = &Media{SiteData: &SiteData{}}
+			return
+		}
+
 		switch goquery.NodeName(s) {
 		case "br":
 			// Line break marks the end of a media item.
 			// Reset newMedia for the next media
 			newMedia = &Media{SiteData: &SiteData{}}
 		case "a":
-			if mp3Source, exists := s.Attr("mp3"); exists {
+			if mp3Source, exists := s.Attr(scraper.Profile.AudioAttribute); exists {
 				newMedia.Source = mp3Source
 				scraper.Lesson.Audio = append(scraper.Lesson.Audio, *newMedia)
 				newMedia = &scraper.Lesson.Audio[len(scraper.Lesson.Audio)-1]
 			} else if pdfSource, exists := s.Attr("href"); exists {
-				scraper.Lesson.Pdf = append(scraper.Lesson.Pdf, pdfSource)
+				scraper.Lesson.Pdf = append(scraper.Lesson.Pdf, scraper.getDocument(s, pdfSource, newMedia))
 			} else {
-				fmt.Println("Error: No source was found.")
+				scraper.Problems = append(scraper.Problems, Problem{
+					Kind:    MissingMediaSource,
+					Excerpt: excerpt(s),
+					Message: "No source was found",
+				})
 			}
 		case "#text":
-			newMedia.Title = getSanatizedTitle(s.Text())
+			// Don't let the white space after a link clear its title.
+			if title := getSanatizedTitle(s.Text()); title != "" {
+				newMedia.Title = title
+			}
 		}
 	})
 }
 
+// getDocument makes a document from a PDF link in the media column. If there's
+// already a class on the same line, the document is for it. The URL is made
+// absolute, from the URL of the page.
+func (scraper *LessonScraper) getDocument(link *goquery.Selection, source string, lineMedia *Media) Document {
+	if absolute := getAbsoluteHref(link, scraper.PageURL); absolute != "" {
+		source = absolute
+	}
+
+	document := Document{
+		URL:   source,
+		Title: getSanatizedTitle(link.Text()),
+		Media: lineMedia.Source,
+		Page:  scraper.PageURL,
+	}
+
+	// The link is often just "PDF", so use the title of the line instead.
+	if document.Title == "" {
+		document.Title = lineMedia.Title
+	}
+
+	return document
+}
+
+// addVideo adds the video to the lesson, with the given title.
+func (scraper *LessonScraper) addVideo(video *goquery.Selection, title string) {
+	source := video.AttrOr("src", "")
+	if source == "" {
+		source = video.Find("source[src]").AttrOr("src", "")
+	}
+	if source == "" {
+		source = video.AttrOr("href", "")
+	}
+
+	if source == "" {
+		scraper.Problems = append(scraper.Problems, Problem{
+			Kind:    MissingMediaSource,
+			Excerpt: excerpt(video),
+			Message: "No video source was found",
+		})
+		return
+	}
+
+	scraper.Lesson.Video = append(scraper.Lesson.Video, Media{
+		SiteData: &SiteData{
+			Title: title,
+		},
+		Source: source,
+		Kind:   getVideoKind(source),
+	})
+}
+
+// getVideoKind gets the kind of video from its source.
+func getVideoKind(source string) MediaKind {
+	host := ""
+	if parsed, err := url.Parse(source); err == nil {
+		host = strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+	}
+
+	switch {
+	case host == "youtu.be" || host == "youtube.com" || strings.HasSuffix(host, ".youtube.com") || host == "youtube-nocookie.com":
+		return YouTubeVideo
+	case host == "vimeo.com" || strings.HasSuffix(host, ".vimeo.com"):
+		return VimeoVideo
+	}
+
+	return VideoFile
+}
+
 // Loads description of lesson, and of media, if available.
 func (scraper *LessonScraper) loadMediaDescription() {
-	mediaParent := scraper.Row.ChildrenFiltered("td:nth-child(3)")
+	mediaParent := scraper.Row.ChildrenFiltered(scraper.Profile.DescriptionColumn)
 	rawDescription := strings.TrimSpace(mediaParent.Text())
 	descriptionParts := strings.Split(rawDescription, "\n")
 
@@ -72,6 +197,8 @@ func (scraper *LessonScraper) loadMediaDescription() {
 		possibleTitle := getSanatizedTitle(part)
 		if matchingAudio := getMediaWithTitle(scraper.Lesson.Audio, possibleTitle); matchingAudio != nil {
 			activeAudio = matchingAudio
+		} else if matchingVideo := getMediaWithTitle(scraper.Lesson.Video, possibleTitle); matchingVideo != nil {
+			activeAudio = matchingVideo
 		} else if activeAudio != nil {
 			activeAudio.Description = separate(activeAudio.Description, part)
 		} else {
@@ -79,12 +206,36 @@ func (scraper *LessonScraper) loadMediaDescription() {
 		}
 	}
 
+	scraper.loadRichDescription(mediaParent)
+
 	for i, audio := range scraper.Lesson.Audio {
 		scraper.Lesson.Audio[i].Description = strings.TrimSpace(audio.Description)
 	}
+	for i, video := range scraper.Lesson.Video {
+		scraper.Lesson.Video[i].Description = strings.TrimSpace(video.Description)
+	}
 	scraper.Lesson.Title = strings.TrimSpace(scraper.Lesson.Title)
 }
 
+// loadRichDescription splits the HTML of the description between the lesson
+// and its media, the same way as the text.
+func (scraper *LessonScraper) loadRichDescription(mediaParent *goquery.Selection) {
+	var activeData *SiteData
+
+	for _, line := range getDescriptionLines(mediaParent, scraper.PageURL) {
+		possibleTitle := getSanatizedTitle(line.Text)
+		if matchingAudio := getMediaWithTitle(scraper.Lesson.Audio, possibleTitle); matchingAudio != nil {
+			activeData = matchingAudio.SiteData
+		} else if matchingVideo := getMediaWithTitle(scraper.Lesson.Video, possibleTitle); matchingVideo != nil {
+			activeData = matchingVideo.SiteData
+		} else if activeData != nil {
+			activeData.DescriptionHTML = separateHTML(activeData.DescriptionHTML, line.HTML)
+		} else {
+			scraper.Lesson.DescriptionHTML = separateHTML(scraper.Lesson.DescriptionHTML, line.HTML)
+		}
+	}
+}
+
 // Separate separates its two arguments with a new line.
 func separate(data1, data2 string) string {
 	if data1 == "" {
@@ -3,8 +3,10 @@ package insidescraper
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 	"testing"
 
+	"github.com/PuerkitoBio/goquery"
 	"github.com/gocolly/colly"
 )
 
@@ -47,3 +49,152 @@ func TestScrapeParentSimpleSection(t *testing.T) {
 func TestPdfCrash(t *testing.T) {
 	runScraper("https://insidechassidus.org/maamarim/maamarim-of-the-rebbe/text-based-concise-summary")
 }
+
+func TestLessonIDIsStable(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<table><tbody>
+		<tr><td>Class One</td><td><a mp3="https://example.com/1.mp3">MP3</a></td><td>A class</td></tr>
+	</tbody></table>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loadID := func(sectionID string, position int) string {
+		scraper := LessonScraper{
+			Row:       doc.Find("tr"),
+			SectionID: sectionID,
+			Position:  position,
+		}
+		scraper.LoadLesson()
+		return scraper.Lesson.ID
+	}
+
+	first := loadID("https://example.com/section", 0)
+
+	if again := loadID("https://example.com/section", 0); again != first {
+		t.Errorf("The same lesson got two IDs: %s and %s", first, again)
+	}
+	if other := loadID("https://example.com/section", 1); other == first {
+		t.Error("Lessons in different rows got the same ID")
+	}
+	if other := loadID("https://example.com/other-section", 0); other == first {
+		t.Error("Lessons in different sections got the same ID")
+	}
+}
+
+func TestLoadVideoLesson(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<table><tbody>
+		<tr><td>Videos</td><td>
+			Class One <a mp3="https://example.com/1.mp3">MP3</a><br>
+			Class Two <p><iframe src="https://www.youtube.com/embed/abc"></iframe></p><br>
+			Class Three <iframe src="https://player.vimeo.com/video/123"></iframe><br>
+			Class Four <a href="https://example.com/4.mp4">Video</a>
+		</td><td>Class Two
+		About the second class</td></tr>
+	</tbody></table>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scraper := LessonScraper{Row: doc.Find("tr")}
+	scraper.LoadLesson()
+	lesson := scraper.Lesson
+
+	if len(lesson.Audio) != 1 || len(lesson.Pdf) != 0 {
+		t.Errorf("Expected only 1 audio class, got %d, and %d PDFs", len(lesson.Audio), len(lesson.Pdf))
+	}
+
+	expected := []Media{
+		{SiteData: &SiteData{Title: "Class Two", Description: "About the second class"}, Source: "https://www.youtube.com/embed/abc", Kind: YouTubeVideo},
+		{SiteData: &SiteData{Title: "Class Three"}, Source: "https://player.vimeo.com/video/123", Kind: VimeoVideo},
+		{SiteData: &SiteData{Title: "Class Four"}, Source: "https://example.com/4.mp4", Kind: VideoFile},
+	}
+
+	if len(lesson.Video) != len(expected) {
+		t.Fatalf("Expected %d videos, got %d", len(expected), len(lesson.Video))
+	}
+
+	for i, video := range lesson.Video {
+		if video.Source != expected[i].Source || video.Kind != expected[i].Kind ||
+			video.Title != expected[i].Title || video.Description != expected[i].Description {
+			t.Errorf("Expected video %+v %+v, got %+v %+v", expected[i], *expected[i].SiteData, video, *video.SiteData)
+		}
+	}
+
+	site := Site{
+		Sections: map[string]SiteSection{
+			"section": {SiteData: &SiteData{}, ID: "section", Lessons: []string{lesson.ID}},
+		},
+		Lessons:  map[string]Lesson{lesson.ID: *lesson},
+		TopLevel: []TopItem{{ID: "section"}},
+	}
+
+	counter := MakeCounter(&site)
+	counter.CountLessons()
+
+	if section := site.Sections["section"]; section.AudioCount != 1 || section.VideoCount != 3 {
+		t.Errorf("Expected 1 audio class and 3 videos, got %d and %d", section.AudioCount, section.VideoCount)
+	}
+
+	resolver := SectionResolver{Site: site}
+	resolver.ResolveSite()
+
+	if resolved, exists := resolver.ResolvedSite.Lessons[lesson.ID]; !exists || len(resolved.Video) != 3 {
+		t.Error("Expected the lesson to be resolved with its videos")
+	}
+	if section := resolver.ResolvedSite.Sections["section"]; section.VideoCount != 3 {
+		t.Errorf("Expected the resolved section to have 3 videos, got %d", section.VideoCount)
+	}
+}
+
+func TestLoadLessonDocuments(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<table><tbody>
+		<tr><td>Documents</td><td>
+			Class One <a mp3="https://example.com/1.mp3">MP3</a> <a href="/1.pdf">PDF</a><br>
+			<a href="/sources.pdf">Source sheet</a>
+		</td><td></td></tr>
+	</tbody></table>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scraper := LessonScraper{Row: doc.Find("tr"), PageURL: "https://example.com/section"}
+	scraper.LoadLesson()
+
+	expected := []Document{
+		{URL: "https://example.com/1.pdf", Title: "Class One", Media: "https://example.com/1.mp3", Page: "https://example.com/section"},
+		{URL: "https://example.com/sources.pdf", Title: "Source sheet", Page: "https://example.com/section"},
+	}
+
+	if len(scraper.Lesson.Pdf) != len(expected) {
+		t.Fatalf("Expected %d PDFs, got %+v", len(expected), scraper.Lesson.Pdf)
+	}
+	for i, document := range scraper.Lesson.Pdf {
+		if document != expected[i] {
+			t.Errorf("Expected %+v, got %+v", expected[i], document)
+		}
+	}
+}
+
+func TestMediaTitleBeforeWhiteSpace(t *testing.T) {
+	// The white space after a link is a text node of its own, which used to
+	// clear the title of the media the link added.
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<table><tbody>
+		<tr><td>White space</td><td>Class One <a mp3="https://example.com/1.mp3">MP3</a>
+			<br>Class Two <a mp3="https://example.com/2.mp3">MP3</a> </td><td></td></tr>
+	</tbody></table>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scraper := LessonScraper{Row: doc.Find("tr")}
+	scraper.LoadLesson()
+
+	if len(scraper.Lesson.Audio) != 2 {
+		t.Fatalf("Expected 2 classes, got %+v", scraper.Lesson.Audio)
+	}
+	for i, title := range []string{"Class One", "Class Two"} {
+		if audio := scraper.Lesson.Audio[i]; audio.Title != title {
+			t.Errorf("Expected class %d to be titled %q, got %q", i+1, title, audio.Title)
+		}
+	}
+}
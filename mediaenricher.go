@@ -0,0 +1,184 @@
+package insidescraper
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// MediaEnricher adds the size, content type, last modified date and duration
+// of each media file on a site. Only the start of each file is downloaded.
+type MediaEnricher struct {
+	Transport http.RoundTripper
+	// PrefixSize is how many bytes at the start of each MP3 are read to find its
+	// duration. Defaults to 16KB.
+	PrefixSize int64
+	// Parallelism is how many files to check at once. Defaults to 1.
+	Parallelism int
+}
+
+// Enrich fills in the details of every audio class of every lesson on the site.
+// Media which couldn't be checked are returned as problems.
+func (enricher *MediaEnricher) Enrich(site *Site) []Problem {
+	// The same file can be in a few lessons, so each one is only checked once.
+	sources := make(map[string]string, len(site.Lessons))
+	for _, lesson := range site.Lessons {
+		for _, media := range lesson.Audio {
+			if _, exists := sources[media.Source]; !exists {
+				sources[media.Source] = lesson.ID
+			}
+		}
+	}
+
+	enriched := make(map[string]Media, len(sources))
+	var problems []Problem
+	var lock sync.Mutex
+	var wait sync.WaitGroup
+
+	queue := make(chan string)
+	parallelism := enricher.Parallelism
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	for i := 0; i < parallelism; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			for source := range queue {
+				media := Media{Source: source}
+				err := enricher.EnrichMedia(&media)
+
+				lock.Lock()
+				enriched[source] = media
+				if err != nil {
+					problems = append(problems, Problem{
+						Kind:    MediaEnrichmentError,
+						URL:     source,
+						Parent:  sources[source],
+						Message: err.Error(),
+					})
+				}
+				lock.Unlock()
+			}
+		}()
+	}
+
+	for source := range sources {
+		queue <- source
+	}
+	close(queue)
+	wait.Wait()
+
+	for id, lesson := range site.Lessons {
+		for i, media := range lesson.Audio {
+			details := enriched[media.Source]
+			lesson.Audio[i].Size = details.Size
+			lesson.Audio[i].ContentType = details.ContentType
+			lesson.Audio[i].LastModified = details.LastModified
+			lesson.Audio[i].Duration = details.Duration
+		}
+		site.Lessons[id] = lesson
+	}
+
+	sort.Slice(problems, func(i, j int) bool {
+		return problems[i].URL < problems[j].URL
+	})
+
+	return problems
+}
+
+// EnrichMedia fills in the details of the media from a HEAD request and, if
+// it's an MP3, its duration from the start of the file.
+func (enricher *MediaEnricher) EnrichMedia(media *Media) error {
+	client := clientFor(enricher.Transport)
+
+	response, err := client.Head(media.Source)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", media.Source, response.Status)
+	}
+
+	if response.ContentLength > 0 {
+		media.Size = response.ContentLength
+	}
+	media.ContentType = response.Header.Get("Content-Type")
+	if modified, err := http.ParseTime(response.Header.Get("Last-Modified")); err == nil {
+		media.LastModified = &modified
+	}
+
+	if !isMP3(media.Source, media.ContentType) {
+		return nil
+	}
+
+	prefixSize := enricher.PrefixSize
+	if prefixSize <= 0 {
+		prefixSize = 16 * 1024
+	}
+
+	prefix, err := readRange(client, media.Source, 0, prefixSize)
+	if err != nil {
+		return err
+	}
+
+	// The ID3 tag can have pictures etc, which are bigger than the prefix.
+	// In that case, read the start of the audio after it.
+	audio := prefix
+	if tagSize := id3Size(prefix); tagSize > 0 {
+		if tagSize < int64(len(prefix)) {
+			audio = prefix[tagSize:]
+		} else if audio, err = readRange(client, media.Source, tagSize, prefixSize); err != nil {
+			return err
+		}
+	}
+
+	media.Duration, err = mp3Duration(prefix, audio, media.Size)
+	return err
+}
+
+// isMP3 checks if the media is an MP3, by its content type or else its extension.
+func isMP3(source, contentType string) bool {
+	if strings.HasPrefix(contentType, "audio/mpeg") || strings.HasPrefix(contentType, "audio/mp3") {
+		return true
+	}
+
+	return strings.EqualFold(path.Ext(strings.SplitN(source, "?", 2)[0]), ".mp3")
+}
+
+// readRange reads length bytes of the file from the given offset. If the
+// server doesn't support ranges, only the needed part of the file is read.
+func readRange(client *http.Client, url string, offset, length int64) ([]byte, error) {
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-"+strconv.FormatInt(offset+length-1, 10))
+
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		if _, err := io.CopyN(ioutil.Discard, response.Body, offset); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%s: %s", url, response.Status)
+	}
+
+	return ioutil.ReadAll(io.LimitReader(response.Body, length))
+}
@@ -0,0 +1,149 @@
+package insidescraper
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mp3Frames makes count frames of a 128kbps, 44.1kHz mono MP3, which are 417
+// bytes each. If xingFrames isn't 0, the first frame has a Xing header.
+func mp3Frames(count int, xingFrames uint32) []byte {
+	var file bytes.Buffer
+
+	for i := 0; i < count; i++ {
+		frame := make([]byte, 417)
+		copy(frame, []byte{0xFF, 0xFB, 0x90, 0xC0})
+
+		if i == 0 && xingFrames != 0 {
+			copy(frame[21:], "Xing")
+			binary.BigEndian.PutUint32(frame[25:], 1)
+			binary.BigEndian.PutUint32(frame[29:], xingFrames)
+		}
+
+		file.Write(frame)
+	}
+
+	return file.Bytes()
+}
+
+// id3Tag makes an ID3v2.3 tag with the given frames, and that much padding.
+func id3Tag(frames map[string]string, padding int) []byte {
+	var body bytes.Buffer
+	for id, text := range frames {
+		body.WriteString(id)
+		binary.Write(&body, binary.BigEndian, uint32(len(text)+1))
+		body.Write([]byte{0, 0, 0})
+		body.WriteString(text)
+	}
+	body.Write(make([]byte, padding))
+
+	size := body.Len()
+	tag := []byte{'I', 'D', '3', 3, 0, 0,
+		byte(size >> 21 & 0x7F), byte(size >> 14 & 0x7F), byte(size >> 7 & 0x7F), byte(size & 0x7F)}
+
+	return append(tag, body.Bytes()...)
+}
+
+var fakeMP3s = map[string][]byte{
+	"/cbr.mp3":    mp3Frames(100, 0),
+	"/vbr.mp3":    mp3Frames(10, 1000),
+	"/tagged.mp3": append(id3Tag(map[string]string{"TLEN": "5000"}, 100), mp3Frames(10, 0)...),
+	// The tag is bigger than the prefix which is read.
+	"/art.mp3": append(id3Tag(nil, 40000), mp3Frames(100, 0)...),
+}
+
+var fakeMP3Modified = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func newFakeMediaServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, exists := fakeMP3s[r.URL.Path]
+		if !exists {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "audio/mpeg")
+		http.ServeContent(w, r, r.URL.Path, fakeMP3Modified, bytes.NewReader(content))
+	}))
+}
+
+func TestEnrichMedia(t *testing.T) {
+	server := newFakeMediaServer()
+	defer server.Close()
+
+	expected := map[string]float64{
+		"/cbr.mp3":    float64(100*417*8) / 128000,
+		"/vbr.mp3":    1000 * 1152 / 44100.0,
+		"/tagged.mp3": 5,
+		"/art.mp3":    float64(100*417*8) / 128000,
+	}
+
+	site := Site{
+		Sections: map[string]SiteSection{
+			"section": {SiteData: &SiteData{}, ID: "section", Lessons: []string{"a", "b"}},
+		},
+		Lessons: map[string]Lesson{
+			"a": {SiteData: &SiteData{}, ID: "a", Audio: []Media{
+				{SiteData: &SiteData{}, Source: server.URL + "/cbr.mp3"},
+				{SiteData: &SiteData{}, Source: server.URL + "/vbr.mp3"},
+			}},
+			"b": {SiteData: &SiteData{}, ID: "b", Audio: []Media{
+				{SiteData: &SiteData{}, Source: server.URL + "/tagged.mp3"},
+				{SiteData: &SiteData{}, Source: server.URL + "/art.mp3"},
+				{SiteData: &SiteData{}, Source: server.URL + "/cbr.mp3"},
+				{SiteData: &SiteData{}, Source: server.URL + "/missing.mp3"},
+			}},
+		},
+		TopLevel: []TopItem{{ID: "section"}},
+	}
+
+	enricher := MediaEnricher{Parallelism: 2}
+	problems := enricher.Enrich(&site)
+
+	if len(problems) != 1 || problems[0].URL != server.URL+"/missing.mp3" || problems[0].Parent != "b" {
+		t.Errorf("Expected a problem with the missing file, got %v", problems)
+	}
+
+	total := 0.0
+	for _, lesson := range site.Lessons {
+		for _, media := range lesson.Audio {
+			path := strings.TrimPrefix(media.Source, server.URL)
+			total += media.Duration
+
+			if path == "/missing.mp3" {
+				continue
+			}
+
+			if math.Abs(media.Duration-expected[path]) > 0.01 {
+				t.Errorf("Expected %s to be %f seconds, got %f", path, expected[path], media.Duration)
+			}
+			if media.Size != int64(len(fakeMP3s[path])) {
+				t.Errorf("Expected %s to be %d bytes, got %d", path, len(fakeMP3s[path]), media.Size)
+			}
+			if media.ContentType != "audio/mpeg" {
+				t.Errorf("Expected %s to be audio/mpeg, got %s", path, media.ContentType)
+			}
+			if media.LastModified == nil || !media.LastModified.Equal(fakeMP3Modified) {
+				t.Errorf("Expected %s to be modified at %s, got %v", path, fakeMP3Modified, media.LastModified)
+			}
+		}
+	}
+
+	counter := MakeCounter(&site)
+	counter.CountLessons()
+
+	if duration := site.Sections["section"].AudioDuration; math.Abs(duration-total) > 0.01 {
+		t.Errorf("Expected the section to be %f seconds, got %f", total, duration)
+	}
+
+	// The CBR file is in two lessons, but it's only counted once.
+	if hours := counter.Hours(); math.Abs(hours-(total-expected["/cbr.mp3"])/3600) > 0.0001 {
+		t.Errorf("Expected %f hours, got %f", (total-expected["/cbr.mp3"])/3600, hours)
+	}
+}
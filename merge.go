@@ -0,0 +1,240 @@
+package insidescraper
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// MergePolicy decides what happens when both sites have an item with the same
+// ID, but different data.
+type MergePolicy string
+
+const (
+	// PreferNewer uses the item from the other site, which is the newer snapshot.
+	PreferNewer MergePolicy = "prefer-newer"
+	// PreferExisting keeps the item which the site already has.
+	PreferExisting MergePolicy = "prefer-existing"
+	// FailOnConflict doesn't merge anything if there are any conflicts.
+	FailOnConflict MergePolicy = "fail-on-conflict"
+)
+
+// MergeChanges are the IDs of the items of one kind which a merge changed.
+type MergeChanges struct {
+	// Added are items which only the other site had.
+	Added []string
+	// Replaced are items which both sites had, which were replaced with the
+	// other site's.
+	Replaced []string
+	// Kept are items which both sites had, where the existing one was kept.
+	Kept []string
+	// Removed are lessons which were only in sections which were replaced, and
+	// which aren't in any section anymore.
+	Removed []string
+}
+
+// MergeReport lists what a merge changed.
+type MergeReport struct {
+	Sections MergeChanges
+	Lessons  MergeChanges
+	TopLevel MergeChanges
+}
+
+// MergeConflictError is returned when a merge with FailOnConflict finds items
+// which are different in the two sites.
+type MergeConflictError struct {
+	Sections []string
+	Lessons  []string
+	TopLevel []string
+}
+
+func (err *MergeConflictError) Error() string {
+	return fmt.Sprintf("Conflicts in %d sections, %d lessons and %d top level items",
+		len(err.Sections), len(err.Lessons), len(err.TopLevel))
+}
+
+// Merge adds the sections, lessons and top level items of the other site, such
+// as a fresh scrape of one branch of the site. Items which are in both sites
+// are resolved by the policy. With FailOnConflict, the site isn't changed if
+// there are any conflicts. A section of the other site without a title, like
+// the start of a branch scrape, keeps its existing title and description.
+// Lessons which only the replaced sections had, and
+// which the other site doesn't have, are removed. Section counts aren't
+// updated; count the site again after merging.
+func (site *Site) Merge(other Site, policy MergePolicy) (MergeReport, error) {
+	switch policy {
+	case PreferNewer, PreferExisting, FailOnConflict:
+	default:
+		return MergeReport{}, fmt.Errorf("Unknown merge policy %q", policy)
+	}
+
+	if site.Sections == nil {
+		site.Sections = make(map[string]SiteSection, len(other.Sections))
+	}
+	if site.Lessons == nil {
+		site.Lessons = make(map[string]Lesson, len(other.Lessons))
+	}
+
+	existingTopLevel := make(map[string]int, len(site.TopLevel))
+	for i, item := range site.TopLevel {
+		existingTopLevel[item.ID] = i
+	}
+
+	// The start section of a branch scrape doesn't have the title and
+	// description which its parent's page gives it.
+	otherSections := make(map[string]SiteSection, len(other.Sections))
+	for id, section := range other.Sections {
+		if existing, exists := site.Sections[id]; exists && existing.SiteData != nil && section.SiteData != nil && section.Title == "" {
+			data := *section.SiteData
+			data.Title, data.Description, data.DescriptionHTML = existing.Title, existing.Description, existing.DescriptionHTML
+			section.SiteData = &data
+		}
+		otherSections[id] = section
+	}
+
+	sections := getMergeChanges(len(otherSections), func(add func(id string, isConflict bool)) {
+		for id, section := range otherSections {
+			existing, exists := site.Sections[id]
+			if !exists {
+				add(id, false)
+			} else if !reflect.DeepEqual(existing, section) {
+				add(id, true)
+			}
+		}
+	})
+	lessons := getMergeChanges(len(other.Lessons), func(add func(id string, isConflict bool)) {
+		for id, lesson := range other.Lessons {
+			existing, exists := site.Lessons[id]
+			if !exists {
+				add(id, false)
+			} else if !reflect.DeepEqual(existing, lesson) {
+				add(id, true)
+			}
+		}
+	})
+	topLevel := getMergeChanges(len(other.TopLevel), func(add func(id string, isConflict bool)) {
+		for _, item := range other.TopLevel {
+			if i, exists := existingTopLevel[item.ID]; !exists {
+				add(item.ID, false)
+			} else if site.TopLevel[i] != item {
+				add(item.ID, true)
+			}
+		}
+	})
+
+	if policy == FailOnConflict && len(sections.Kept)+len(lessons.Kept)+len(topLevel.Kept) > 0 {
+		return MergeReport{}, &MergeConflictError{
+			Sections: sections.Kept,
+			Lessons:  lessons.Kept,
+			TopLevel: topLevel.Kept,
+		}
+	}
+
+	// Until now, every conflict was listed as kept.
+	if policy == PreferNewer {
+		for _, changes := range []*MergeChanges{&sections, &lessons, &topLevel} {
+			changes.Replaced, changes.Kept = changes.Kept, make([]string, 0)
+		}
+	}
+
+	// The lessons of the sections which are replaced might not be in any
+	// section afterwards.
+	replacedLessons := make([]string, 0)
+	for _, id := range sections.Replaced {
+		replacedLessons = append(replacedLessons, site.Sections[id].Lessons...)
+	}
+
+	for _, ids := range [][]string{sections.Added, sections.Replaced} {
+		for _, id := range ids {
+			site.setSection(id, otherSections[id])
+		}
+	}
+	for _, ids := range [][]string{lessons.Added, lessons.Replaced} {
+		for _, id := range ids {
+			site.Lessons[id] = other.Lessons[id]
+		}
+	}
+
+	isRemoved := make(map[string]bool)
+	for _, id := range replacedLessons {
+		if _, inOther := other.Lessons[id]; inOther || isRemoved[id] || len(site.lessonParents(id)) > 0 {
+			continue
+		}
+		if _, exists := site.Lessons[id]; exists {
+			isRemoved[id] = true
+			delete(site.Lessons, id)
+			lessons.Removed = append(lessons.Removed, id)
+		}
+	}
+	sort.Strings(lessons.Removed)
+
+	// New top level items keep the order they have in the other site.
+	isAdded := make(map[string]bool, len(topLevel.Added))
+	for _, id := range topLevel.Added {
+		isAdded[id] = true
+	}
+	isReplaced := make(map[string]bool, len(topLevel.Replaced))
+	for _, id := range topLevel.Replaced {
+		isReplaced[id] = true
+	}
+	for _, item := range other.TopLevel {
+		if isAdded[item.ID] {
+			site.TopLevel = append(site.TopLevel, item)
+		} else if isReplaced[item.ID] {
+			site.TopLevel[existingTopLevel[item.ID]] = item
+		}
+	}
+
+	return MergeReport{
+		Sections: sections,
+		Lessons:  lessons,
+		TopLevel: topLevel,
+	}, nil
+}
+
+// getMergeChanges collects the IDs of the new items, and of the conflicts,
+// which are listed as kept. They're sorted.
+func getMergeChanges(size int, find func(add func(id string, isConflict bool))) MergeChanges {
+	changes := MergeChanges{
+		Added:    make([]string, 0, size),
+		Replaced: make([]string, 0),
+		Kept:     make([]string, 0),
+		Removed:  make([]string, 0),
+	}
+
+	find(func(id string, isConflict bool) {
+		if isConflict {
+			changes.Kept = append(changes.Kept, id)
+		} else {
+			changes.Added = append(changes.Added, id)
+		}
+	})
+
+	sort.Strings(changes.Added)
+	sort.Strings(changes.Kept)
+
+	return changes
+}
+
+// Merge merges the site of the other file into this one's. With PreferNewer,
+// the site which finished being scraped later is preferred; if that's not
+// known, the other one is. The file's times are widened to cover both scrapes.
+func (file *SiteFile) Merge(other SiteFile, policy MergePolicy) (MergeReport, error) {
+	if policy == PreferNewer && file.EndTime != nil && other.EndTime != nil && other.EndTime.Before(*file.EndTime) {
+		policy = PreferExisting
+	}
+
+	report, err := file.Site.Merge(other.Site, policy)
+	if err != nil {
+		return report, err
+	}
+
+	if other.StartTime != nil && (file.StartTime == nil || other.StartTime.Before(*file.StartTime)) {
+		file.StartTime = other.StartTime
+	}
+	if other.EndTime != nil && (file.EndTime == nil || other.EndTime.After(*file.EndTime)) {
+		file.EndTime = other.EndTime
+	}
+
+	return report, nil
+}
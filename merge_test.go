@@ -0,0 +1,200 @@
+package insidescraper
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func getMergeSites() (Site, Site) {
+	existing := Site{
+		TopLevel: []TopItem{{ID: "top", Image: "top.png"}, {ID: "sichos"}},
+		Sections: map[string]SiteSection{
+			"top":    {SiteData: &SiteData{Title: "Top"}, ID: "top", Lessons: []string{"same", "shared"}},
+			"sichos": {SiteData: &SiteData{Title: "Sichos"}, ID: "sichos", Lessons: []string{"old", "gone", "shared"}},
+		},
+		Lessons: map[string]Lesson{
+			"same":   {SiteData: &SiteData{Title: "Same"}, ID: "same"},
+			"old":    {SiteData: &SiteData{Title: "Old"}, ID: "old"},
+			"gone":   {SiteData: &SiteData{Title: "Gone"}, ID: "gone"},
+			"shared": {SiteData: &SiteData{Title: "Shared"}, ID: "shared"},
+		},
+	}
+
+	branch := Site{
+		TopLevel: []TopItem{{ID: "sichos", Image: "sichos.png"}, {ID: "new-top"}},
+		Sections: map[string]SiteSection{
+			"sichos":  {SiteData: &SiteData{Title: "Sichos"}, ID: "sichos", Lessons: []string{"old", "new"}},
+			"new-top": {SiteData: &SiteData{Title: "New"}, ID: "new-top"},
+		},
+		Lessons: map[string]Lesson{
+			"same": {SiteData: &SiteData{Title: "Same"}, ID: "same"},
+			"old":  {SiteData: &SiteData{Title: "Old, fixed"}, ID: "old"},
+			"new":  {SiteData: &SiteData{Title: "New"}, ID: "new"},
+		},
+	}
+
+	return existing, branch
+}
+
+func TestMergePreferNewer(t *testing.T) {
+	site, branch := getMergeSites()
+
+	report, err := site.Merge(branch, PreferNewer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := MergeReport{
+		Sections: MergeChanges{Added: []string{"new-top"}, Replaced: []string{"sichos"}, Kept: []string{}, Removed: []string{}},
+		// "gone" was only in the old sichos, but "shared" is in top too.
+		Lessons:  MergeChanges{Added: []string{"new"}, Replaced: []string{"old"}, Kept: []string{}, Removed: []string{"gone"}},
+		TopLevel: MergeChanges{Added: []string{"new-top"}, Replaced: []string{"sichos"}, Kept: []string{}, Removed: []string{}},
+	}
+	if !reflect.DeepEqual(report, expected) {
+		t.Errorf("Expected report\n%+v\ngot\n%+v", expected, report)
+	}
+
+	expectedTopLevel := []TopItem{{ID: "top", Image: "top.png"}, {ID: "sichos", Image: "sichos.png"}, {ID: "new-top"}}
+	if !reflect.DeepEqual(site.TopLevel, expectedTopLevel) {
+		t.Errorf("Expected top level %v, got %v", expectedTopLevel, site.TopLevel)
+	}
+	if site.Lessons["old"].Title != "Old, fixed" {
+		t.Errorf("Expected the lesson to be replaced, got %q", site.Lessons["old"].Title)
+	}
+	if parents := site.Parents("new"); !reflect.DeepEqual(parents, []string{"sichos"}) {
+		t.Errorf("Expected the new lesson to be in sichos, got %v", parents)
+	}
+	if _, exists := site.Lessons["gone"]; exists {
+		t.Errorf("Expected the lesson which isn't in a section anymore to be removed")
+	}
+	if _, exists := site.Lessons["shared"]; !exists {
+		t.Errorf("Expected the lesson which is still in top to be kept")
+	}
+}
+
+func TestMergePreferExisting(t *testing.T) {
+	site, branch := getMergeSites()
+
+	report, err := site.Merge(branch, PreferExisting)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(report.Lessons.Kept, []string{"old"}) || len(report.Lessons.Replaced) != 0 {
+		t.Errorf("Expected the existing lesson to be kept, got %+v", report.Lessons)
+	}
+	if site.Lessons["old"].Title != "Old" || len(site.Sections["sichos"].Lessons) != 3 {
+		t.Errorf("Expected the existing data to be kept")
+	}
+	if _, exists := site.Lessons["new"]; !exists {
+		t.Errorf("Expected the new lesson to be added")
+	}
+	if len(report.Lessons.Removed) != 0 {
+		t.Errorf("Expected nothing to be removed, got %v", report.Lessons.Removed)
+	}
+}
+
+func TestMergeFailOnConflict(t *testing.T) {
+	site, branch := getMergeSites()
+
+	_, err := site.Merge(branch, FailOnConflict)
+	conflict, isConflict := err.(*MergeConflictError)
+	if !isConflict {
+		t.Fatalf("Expected a MergeConflictError, got %v", err)
+	}
+
+	expected := &MergeConflictError{Sections: []string{"sichos"}, Lessons: []string{"old"}, TopLevel: []string{"sichos"}}
+	if !reflect.DeepEqual(conflict, expected) {
+		t.Errorf("Expected conflicts %+v, got %+v", expected, conflict)
+	}
+
+	if _, exists := site.Lessons["new"]; exists {
+		t.Errorf("Expected nothing to be merged")
+	}
+}
+
+func TestMergeOlderFile(t *testing.T) {
+	site, branch := getMergeSites()
+	earlier := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	later := earlier.Add(time.Hour)
+
+	file := SiteFile{StartTime: &later, EndTime: &later, Site: site}
+	report, err := file.Merge(SiteFile{StartTime: &earlier, EndTime: &earlier, Site: branch}, PreferNewer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(report.Lessons.Kept, []string{"old"}) {
+		t.Errorf("Expected the newer lesson to be kept, got %+v", report.Lessons)
+	}
+	if !file.StartTime.Equal(earlier) || !file.EndTime.Equal(later) {
+		t.Errorf("Expected the times to cover both scrapes, got %v to %v", file.StartTime, file.EndTime)
+	}
+}
+
+func TestMergeBranchScrape(t *testing.T) {
+	server := newFakeSite()
+	defer server.Close()
+
+	branchID := server.URL + "/section-a"
+	subID := server.URL + "/section-a/sub"
+
+	// The CLI's -url sets BaseURL, and the API can also be passed the URL.
+	scrapes := map[string]func() (Site, error){
+		"BaseURL": func() (Site, error) {
+			options := fakeSiteOptions(server)
+			options.BaseURL = branchID
+			branch := InsideScraper{Options: options}
+			_, err := branch.Scrape()
+			return branch.Site, err
+		},
+		"Scrape": func() (Site, error) {
+			branch := InsideScraper{Options: fakeSiteOptions(server)}
+			_, err := branch.Scrape(branchID)
+			return branch.Site, err
+		},
+	}
+
+	for name, scrape := range scrapes {
+		full := InsideScraper{Options: fakeSiteOptions(server)}
+		if _, err := full.Scrape(); err != nil {
+			t.Fatal(err)
+		}
+
+		branch, err := scrape()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, exists := branch.Sections[""]; exists {
+			t.Errorf("%s: expected the start page to be section A, not a section without an ID", name)
+		}
+		root := branch.Sections[branchID]
+		if !reflect.DeepEqual(root.Sections, []string{subID}) || !reflect.DeepEqual(root.Lessons, full.Site.Sections[branchID].Lessons) {
+			t.Errorf("%s: expected section A to have its lesson and sub section, got %+v", name, root)
+		}
+		if sub := branch.Sections[subID]; len(sub.Lessons) == 0 || !reflect.DeepEqual(sub.Lessons, full.Site.Sections[subID].Lessons) {
+			t.Errorf("%s: expected the branch to have the lessons of the full scrape, got %v", name, sub.Lessons)
+		}
+
+		site := full.Site
+		report, err := site.Merge(branch, PreferNewer)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Nothing changed on the site since the full scrape.
+		for _, changes := range []MergeChanges{report.Sections, report.Lessons, report.TopLevel} {
+			if len(changes.Added)+len(changes.Replaced)+len(changes.Kept)+len(changes.Removed) != 0 {
+				t.Errorf("%s: expected the merge not to change anything, got %+v", name, report)
+			}
+		}
+		if _, exists := site.Sections[""]; exists {
+			t.Errorf("%s: expected the merged site not to have a section without an ID", name)
+		}
+		if site.Sections[branchID].Title != "Section A" {
+			t.Errorf("%s: expected section A to keep its title, got %q", name, site.Sections[branchID].Title)
+		}
+	}
+}
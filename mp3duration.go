@@ -0,0 +1,209 @@
+package insidescraper
+
+import (
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Bitrates in kbps, by MPEG version, then layer, then bitrate index.
+var mp3Bitrates = map[bool]map[int][16]int{
+	// MPEG 1
+	true: {
+		1: {0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448, 0},
+		2: {0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384, 0},
+		3: {0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0},
+	},
+	// MPEG 2 and 2.5
+	false: {
+		1: {0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256, 0},
+		2: {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+		3: {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+	},
+}
+
+// Sample rates by version bits.
+var mp3SampleRates = map[byte][3]int{
+	0: {11025, 12000, 8000},  // MPEG 2.5
+	2: {22050, 24000, 16000}, // MPEG 2
+	3: {44100, 48000, 32000}, // MPEG 1
+}
+
+// mp3Frame is the header of an MP3 frame.
+type mp3Frame struct {
+	isMPEG1    bool
+	layer      int
+	bitrate    int
+	sampleRate int
+	isMono     bool
+}
+
+// samplesPerFrame is how many samples of audio are in each frame.
+func (frame mp3Frame) samplesPerFrame() int {
+	switch {
+	case frame.layer == 1:
+		return 384
+	case frame.layer == 3 && !frame.isMPEG1:
+		return 576
+	}
+	return 1152
+}
+
+// xingOffset is where a Xing header would be, from the start of the frame.
+func (frame mp3Frame) xingOffset() int {
+	switch {
+	case frame.isMPEG1 && !frame.isMono:
+		return 4 + 32
+	case frame.isMPEG1 || !frame.isMono:
+		return 4 + 17
+	}
+	return 4 + 9
+}
+
+// parseMP3Frame reads a frame header, if the bytes are one.
+func parseMP3Frame(header []byte) (mp3Frame, bool) {
+	if len(header) < 4 || header[0] != 0xFF || header[1]&0xE0 != 0xE0 {
+		return mp3Frame{}, false
+	}
+
+	version := header[1] >> 3 & 3
+	layerBits := header[1] >> 1 & 3
+	bitrateIndex := header[2] >> 4
+	sampleRateIndex := header[2] >> 2 & 3
+
+	if version == 1 || layerBits == 0 || bitrateIndex == 0 || bitrateIndex == 15 || sampleRateIndex == 3 {
+		return mp3Frame{}, false
+	}
+
+	frame := mp3Frame{
+		isMPEG1: version == 3,
+		layer:   int(4 - layerBits),
+		isMono:  header[3]>>6 == 3,
+	}
+	frame.bitrate = mp3Bitrates[frame.isMPEG1][frame.layer][bitrateIndex] * 1000
+	frame.sampleRate = mp3SampleRates[version][sampleRateIndex]
+
+	return frame, true
+}
+
+// id3Size gets the size of the ID3v2 tag at the start of the file, or 0 if there isn't one.
+func id3Size(prefix []byte) int64 {
+	if len(prefix) < 10 || string(prefix[:3]) != "ID3" {
+		return 0
+	}
+
+	size := int64(syncSafe(prefix[6:10])) + 10
+	// A footer is the same size as the header.
+	if prefix[5]&0x10 != 0 {
+		size += 10
+	}
+
+	return size
+}
+
+// syncSafe decodes an ID3 integer, which uses 7 bits of each byte.
+func syncSafe(data []byte) int {
+	value := 0
+	for _, b := range data {
+		value = value<<7 | int(b&0x7F)
+	}
+	return value
+}
+
+// id3Length gets the length of the audio from the TLEN frame of the ID3 tag, in seconds.
+func id3Length(prefix []byte) (float64, bool) {
+	tagSize := id3Size(prefix)
+	if tagSize == 0 {
+		return 0, false
+	}
+	if int64(len(prefix)) < tagSize {
+		tagSize = int64(len(prefix))
+	}
+
+	version := prefix[3]
+	position := int64(10)
+
+	for position+10 <= tagSize {
+		id := string(prefix[position : position+4])
+		if id[0] == 0 {
+			break
+		}
+
+		var size int64
+		if version >= 4 {
+			size = int64(syncSafe(prefix[position+4 : position+8]))
+		} else {
+			size = int64(binary.BigEndian.Uint32(prefix[position+4 : position+8]))
+		}
+
+		start, end := position+10, position+10+size
+		if end > tagSize {
+			break
+		}
+
+		// The first byte is the text encoding.
+		if id == "TLEN" && size > 1 {
+			text := strings.Trim(string(prefix[start+1:end]), "\x00 ")
+			if milliseconds, err := strconv.ParseFloat(text, 64); err == nil && milliseconds > 0 {
+				return milliseconds / 1000, true
+			}
+		}
+
+		position = end
+	}
+
+	return 0, false
+}
+
+// mp3Duration finds the length of an MP3 in seconds, from the start of the file
+// and the size of the whole file. audio is the start of the file after the
+// ID3 tag, if there is one.
+// The length is read from the ID3 tag, or a Xing or VBRI header. Otherwise,
+// the file is assumed to have a constant bitrate.
+func mp3Duration(prefix, audio []byte, fileSize int64) (float64, error) {
+	if seconds, exists := id3Length(prefix); exists {
+		return seconds, nil
+	}
+
+	// Find the first frame.
+	start := -1
+	var frame mp3Frame
+	for i := 0; i+4 <= len(audio); i++ {
+		var isFrame bool
+		if frame, isFrame = parseMP3Frame(audio[i : i+4]); isFrame {
+			start = i
+			break
+		}
+	}
+
+	if start == -1 {
+		return 0, errors.New("no MP3 frame found")
+	}
+
+	frameData := audio[start:]
+
+	// Variable bitrate files have a header with the number of frames.
+	frames := uint32(0)
+	if offset := frame.xingOffset(); len(frameData) >= offset+12 {
+		tag := string(frameData[offset : offset+4])
+		flags := binary.BigEndian.Uint32(frameData[offset+4 : offset+8])
+		if (tag == "Xing" || tag == "Info") && flags&1 != 0 {
+			frames = binary.BigEndian.Uint32(frameData[offset+8 : offset+12])
+		}
+	}
+	if frames == 0 && len(frameData) >= 36+18 && string(frameData[36:40]) == "VBRI" {
+		frames = binary.BigEndian.Uint32(frameData[36+14 : 36+18])
+	}
+
+	if frames != 0 {
+		return float64(frames) * float64(frame.samplesPerFrame()) / float64(frame.sampleRate), nil
+	}
+
+	audioSize := fileSize - id3Size(prefix) - int64(start)
+	if audioSize <= 0 {
+		return 0, errors.New("unknown file size")
+	}
+
+	return float64(audioSize) * 8 / float64(frame.bitrate), nil
+}
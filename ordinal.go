@@ -0,0 +1,261 @@
+package insidescraper
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Ordinal is the position of a class, as written in its title. For example,
+// "Class Three, Part 2" is class 3, part 2, and "Class One/ Five (המשך)" is
+// class 1, which is also known as class 5, and continues an earlier class.
+type Ordinal struct {
+	Class int `json:",omitempty"`
+	Part  int `json:",omitempty"`
+	// Alternate is the second number of a class which has two.
+	Alternate int `json:",omitempty"`
+	// Continued is set if the class continues the previous one.
+	Continued bool `json:",omitempty"`
+}
+
+// Words which come before the number of a class or a part.
+var (
+	classWords = map[string]bool{"class": true, "lesson": true, "shiur": true, "שיעור": true}
+	partWords  = map[string]bool{"part": true, "חלק": true}
+	// Words which mark a class which continues the one before it.
+	continuedWords = map[string]bool{"continued": true, "cont": true, "המשך": true}
+)
+
+var englishNumbers = map[string]int{
+	"one": 1, "two": 2, "three": 3, "four": 4, "five": 5, "six": 6, "seven": 7,
+	"eight": 8, "nine": 9, "ten": 10, "eleven": 11, "twelve": 12, "thirteen": 13,
+	"fourteen": 14, "fifteen": 15, "sixteen": 16, "seventeen": 17, "eighteen": 18,
+	"nineteen": 19,
+	"first":    1, "second": 2, "third": 3, "fourth": 4, "fifth": 5, "sixth": 6,
+	"seventh": 7, "eighth": 8, "ninth": 9, "tenth": 10, "eleventh": 11,
+	"twelfth": 12, "thirteenth": 13, "fourteenth": 14, "fifteenth": 15,
+	"sixteenth": 16, "seventeenth": 17, "eighteenth": 18, "nineteenth": 19,
+}
+
+var englishTens = map[string]int{
+	"twenty": 20, "thirty": 30, "forty": 40, "fifty": 50, "sixty": 60,
+	"seventy": 70, "eighty": 80, "ninety": 90,
+	"twentieth": 20, "thirtieth": 30, "fortieth": 40, "fiftieth": 50,
+	"sixtieth": 60, "seventieth": 70, "eightieth": 80, "ninetieth": 90,
+}
+
+var hebrewNumbers = map[rune]int{
+	'א': 1, 'ב': 2, 'ג': 3, 'ד': 4, 'ה': 5, 'ו': 6, 'ז': 7, 'ח': 8, 'ט': 9,
+	'י': 10, 'כ': 20, 'ך': 20, 'ל': 30, 'מ': 40, 'ם': 40, 'נ': 50, 'ן': 50,
+	'ס': 60, 'ע': 70, 'פ': 80, 'ף': 80, 'צ': 90, 'ץ': 90,
+	'ק': 100, 'ר': 200, 'ש': 300, 'ת': 400,
+}
+
+// Marks which show that Hebrew letters are a number.
+const hebrewNumberMarks = "'\"׳״"
+
+// ParseOrdinal reads the class and part numbers from a title. Numbers can be
+// digits, English words or Hebrew letters. The second value is false if the
+// title doesn't have a class number.
+func ParseOrdinal(title string) (Ordinal, bool) {
+	tokens := getOrdinalTokens(title)
+	var ordinal Ordinal
+
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+
+		switch {
+		case continuedWords[token]:
+			ordinal.Continued = true
+		case classWords[token] && ordinal.Class == 0:
+			if value, next, isNumber := parseNumber(tokens, i+1, true); isNumber {
+				ordinal.Class = value
+				i = next - 1
+
+				// A second number, like "Class One/ Five".
+				if next+1 < len(tokens) && (tokens[next] == "/" || tokens[next] == ",") {
+					if value, next, isNumber := parseNumber(tokens, next+1, true); isNumber {
+						ordinal.Alternate = value
+						i = next - 1
+					}
+				}
+			}
+		case partWords[token] && ordinal.Part == 0:
+			if value, next, isNumber := parseNumber(tokens, i+1, true); isNumber {
+				ordinal.Part = value
+				i = next - 1
+			}
+		}
+	}
+
+	// A title which is just a number, like "2", "Three" or "י״א".
+	if ordinal.Class == 0 && len(tokens) > 0 {
+		if value, next, isNumber := parseNumber(tokens, 0, false); isNumber && next == len(tokens) {
+			ordinal.Class = value
+		}
+	}
+
+	return ordinal, ordinal.Class != 0
+}
+
+// getOrdinalTokens splits the title into lower case words, and the slashes and
+// commas between them.
+func getOrdinalTokens(title string) []string {
+	var tokens []string
+	var word strings.Builder
+
+	endWord := func() {
+		if word.Len() > 0 {
+			tokens = append(tokens, word.String())
+			word.Reset()
+		}
+	}
+
+	for _, r := range strings.ToLower(title) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune(hebrewNumberMarks, r):
+			word.WriteRune(r)
+		case r == '/' || r == ',':
+			endWord()
+			tokens = append(tokens, string(r))
+		default:
+			endWord()
+		}
+	}
+	endWord()
+
+	return tokens
+}
+
+// parseNumber reads the number which starts at the given token. It returns the
+// number, and the index of the token after it. afterWord is set if the token
+// comes after a word like "class", which says that it's a number.
+func parseNumber(tokens []string, i int, afterWord bool) (int, int, bool) {
+	if i >= len(tokens) {
+		return 0, i, false
+	}
+
+	token := tokens[i]
+
+	// Digits, with an optional suffix, like "2" or "2nd".
+	digits := strings.TrimRightFunc(token, unicode.IsLetter)
+	if value, err := strconv.Atoi(digits); err == nil && value > 0 {
+		if suffix := token[len(digits):]; suffix == "" || suffix == "st" || suffix == "nd" || suffix == "rd" || suffix == "th" {
+			return value, i + 1, true
+		}
+	}
+
+	if value, exists := englishNumbers[token]; exists {
+		return value, i + 1, true
+	}
+
+	if tens, exists := englishTens[token]; exists {
+		// Like "twenty one" (which was "twenty-one").
+		if i+1 < len(tokens) {
+			if units, exists := englishNumbers[tokens[i+1]]; exists && units < 10 {
+				return tens + units, i + 2, true
+			}
+		}
+		return tens, i + 1, true
+	}
+
+	if value, isNumber := parseHebrewNumber(token, afterWord); isNumber {
+		return value, i + 1, true
+	}
+
+	return 0, i, false
+}
+
+// parseHebrewNumber reads a number written in Hebrew letters, like "ה" or "י״א".
+// Short words look like numbers, so letters without a geresh or gershayim are
+// only taken to be a number after a word like "class", and only if they're one
+// or two letters which are written the way a number is, so that "שיעור על" isn't
+// class 100.
+func parseHebrewNumber(token string, afterWord bool) (int, bool) {
+	if !strings.ContainsAny(token, hebrewNumberMarks) && (!afterWord || len([]rune(token)) > 2) {
+		return 0, false
+	}
+
+	letters := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(hebrewNumberMarks, r) {
+			return -1
+		}
+		return r
+	}, token)
+
+	// 15 and 16 are written as 9 and 6 or 7, so that they don't spell a name of God.
+	switch letters {
+	case "טו":
+		return 15, true
+	case "טז":
+		return 16, true
+	}
+
+	// The letters go from the hundreds to the tens to the units, with at most
+	// one letter each for the tens and the units.
+	value, lastPlace := 0, 3
+	for _, r := range letters {
+		letterValue, exists := hebrewNumbers[r]
+		if !exists {
+			return 0, false
+		}
+
+		place := 0
+		if letterValue >= 100 {
+			place = 2
+		} else if letterValue >= 10 {
+			place = 1
+		}
+		if place > lastPlace || (place == lastPlace && place != 2) {
+			return 0, false
+		}
+
+		value += letterValue
+		lastPlace = place
+	}
+
+	return value, value > 0
+}
+
+// setOrdinals parses the ordinals of the lesson and of all of its media.
+func setOrdinals(lesson *Lesson) {
+	if lesson.SiteData != nil {
+		if ordinal, exists := ParseOrdinal(lesson.Title); exists {
+			lesson.Ordinal = &ordinal
+		}
+	}
+
+	for _, media := range [][]Media{lesson.Audio, lesson.Video} {
+		for i := range media {
+			if media[i].SiteData == nil {
+				continue
+			}
+			if ordinal, exists := ParseOrdinal(media[i].Title); exists {
+				media[i].Ordinal = &ordinal
+			}
+		}
+	}
+}
+
+// SortAudio sorts the lesson's audio by their ordinals, because the site
+// sometimes lists classes out of order. It's only sorted if every class has an
+// ordinal; otherwise there's no way to tell where the others belong.
+func (lesson *Lesson) SortAudio() {
+	for _, media := range lesson.Audio {
+		if media.Ordinal == nil {
+			return
+		}
+	}
+
+	sort.SliceStable(lesson.Audio, func(i, j int) bool {
+		a, b := lesson.Audio[i].Ordinal, lesson.Audio[j].Ordinal
+		if a.Class != b.Class {
+			return a.Class < b.Class
+		}
+		if a.Part != b.Part {
+			return a.Part < b.Part
+		}
+		return !a.Continued && b.Continued
+	})
+}
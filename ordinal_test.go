@@ -0,0 +1,85 @@
+package insidescraper
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestParseOrdinal(t *testing.T) {
+	tests := map[string]Ordinal{
+		"Class One":                 {Class: 1},
+		"Class 2":                   {Class: 2},
+		"class twenty-three":        {Class: 23},
+		"Lesson 4th":                {Class: 4},
+		"Class One/ Five (המשך)":    {Class: 1, Alternate: 5, Continued: true},
+		"Class Three, Part 2":       {Class: 3, Part: 2},
+		"Class Seven - Part Two":    {Class: 7, Part: 2},
+		"שיעור ה":                   {Class: 5},
+		"שיעור י״א":                 {Class: 11},
+		"שיעור יב חלק ב":            {Class: 12, Part: 2},
+		"שיעור טו":                  {Class: 15},
+		"י״א":                       {Class: 11},
+		"Eight":                     {Class: 8},
+		"12":                        {Class: 12},
+		"Class Fifth (continued)":   {Class: 5, Continued: true},
+		"Shiur 3: The Soul's Roots": {Class: 3},
+	}
+
+	for title, expected := range tests {
+		if ordinal, exists := ParseOrdinal(title); !exists || ordinal != expected {
+			t.Errorf("Expected %q to be %+v, got %+v", title, expected, ordinal)
+		}
+	}
+
+	for _, title := range []string{"", "Introduction", "Tanya Chapter 1", "Class המשך", "בראשית", "Part 2",
+		// Hebrew words which look like numbers.
+		"שיעור על המידות", "שיעור בו", "על", "לו", "חלק אב"} {
+		if ordinal, exists := ParseOrdinal(title); exists {
+			t.Errorf("Expected %q not to have an ordinal, got %+v", title, ordinal)
+		}
+	}
+}
+
+func TestSortAudio(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<table><tbody>
+		<tr><td>Out of order</td><td>
+			Class Three <a mp3="https://example.com/3.mp3">MP3</a><br>
+			Class One <a mp3="https://example.com/1.mp3">MP3</a><br>
+			Class Two <a mp3="https://example.com/2.mp3">MP3</a>
+		</td><td></td></tr>
+	</tbody></table>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unsorted := LessonScraper{Row: doc.Find("tr")}
+	unsorted.LoadLesson()
+	sorted := LessonScraper{Row: doc.Find("tr"), SortAudio: true}
+	sorted.LoadLesson()
+
+	if sorted.Lesson.ID != unsorted.Lesson.ID {
+		t.Error("Sorting changed the lesson's ID")
+	}
+
+	if unsorted.Lesson.Audio[0].Ordinal.Class != 3 {
+		t.Errorf("Expected the first class to be unsorted, got %+v", *unsorted.Lesson.Audio[0].Ordinal)
+	}
+
+	for i, media := range sorted.Lesson.Audio {
+		if media.Ordinal == nil || media.Ordinal.Class != i+1 {
+			t.Errorf("Expected class %d at %d, got %s", i+1, i, media.Title)
+		}
+	}
+
+	// A lesson with a class which has no ordinal isn't sorted.
+	lesson := Lesson{Audio: []Media{
+		{SiteData: &SiteData{Title: "Class Two"}, Ordinal: &Ordinal{Class: 2}},
+		{SiteData: &SiteData{Title: "Introduction"}},
+	}}
+	lesson.SortAudio()
+	if lesson.Audio[0].Title != "Class Two" {
+		t.Error("Expected a lesson with classes without ordinals not to be sorted")
+	}
+}
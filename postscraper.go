@@ -2,7 +2,6 @@ package insidescraper
 
 import (
 	"fmt"
-	"io/ioutil"
 	"math"
 	"net/http"
 	"path"
@@ -24,11 +23,32 @@ const (
 	MediaType
 )
 
+// String gets the name of the type, like "section".
+func (dataType DataType) String() string {
+	switch dataType {
+	case SectionType:
+		return "section"
+	case LessonType:
+		return "lesson"
+	case MediaType:
+		return "media"
+	}
+
+	return fmt.Sprintf("DataType(%d)", int(dataType))
+}
+
 // PostScraper goes over the scraped data and fixes it up as much as possible.
 type PostScraper struct {
 	Site    Site
 	Missing map[string]Correction
 	Empty   map[string]Correction
+	// Transport makes the requests which check corrections. If nil, http.DefaultTransport is used.
+	Transport http.RoundTripper
+	// Profile finds the content of pages, to check if two pages are the same.
+	// Unset fields use their defaults.
+	Profile SiteProfile
+	// Problems are the corrections which couldn't be checked.
+	Problems []Problem
 }
 
 // Correction is a (possible) correction for a missing link.
@@ -62,13 +82,15 @@ func (cleaner *PostScraper) FixSite() {
 func (cleaner *PostScraper) GetMissingCorrections() map[string]Correction {
 	corrections := make(map[string]Correction, 10)
 
-	for parentID, section := range cleaner.Site.Sections {
+	for _, section := range cleaner.Site.Sections {
 		for _, subSectionID := range section.Sections {
 			// Don't find correction twice.
 			if _, exists := corrections[subSectionID]; exists {
-				addParent(corrections, subSectionID, parentID)
-			} else if _, exists := cleaner.Site.Sections[subSectionID]; !exists {
-				corrections[subSectionID] = cleaner.getPossibleMatches(subSectionID, parentID)
+				continue
+			}
+
+			if _, exists := cleaner.Site.Sections[subSectionID]; !exists {
+				corrections[subSectionID] = cleaner.getPossibleMatches(subSectionID)
 				// No need to test for lessons here. If this sectionID really references a lesson,
 				// it would already have been converted in the scraper.
 				// And if it incorrectly references a lesson, that will get picked up in getPossibleMatches, also.
@@ -79,25 +101,20 @@ func (cleaner *PostScraper) GetMissingCorrections() map[string]Correction {
 	return corrections
 }
 
-// addParent registers the given section as referencing the given bad section/lesson ID.
-func addParent(corrections map[string]Correction, badID, parentID string) {
-	correction, _ := corrections[badID]
-	correction.Parents = append(correction.Parents, parentID)
-	corrections[badID] = correction
-}
-
 // GetEmptyCorrections finds all empty sections (no lessons or subsections) and tries to correct.
 func (cleaner *PostScraper) GetEmptyCorrections() map[string]Correction {
 	corrections := make(map[string]Correction, 10)
 
-	for parentID, section := range cleaner.Site.Sections {
+	for _, section := range cleaner.Site.Sections {
 		for _, subSectionID := range section.Sections {
 			// Don't try to correct the same thing twice.
 			if _, exists := corrections[subSectionID]; exists {
-				addParent(corrections, subSectionID, parentID)
-			} else if subSection, exists := cleaner.Site.Sections[subSectionID]; exists {
+				continue
+			}
+
+			if subSection, exists := cleaner.Site.Sections[subSectionID]; exists {
 				if len(subSection.Sections) == 0 && len(subSection.Lessons) == 0 {
-					corrections[subSectionID] = cleaner.getPossibleMatches(subSectionID, parentID)
+					corrections[subSectionID] = cleaner.getPossibleMatches(subSectionID)
 				}
 			}
 		}
@@ -105,10 +122,12 @@ func (cleaner *PostScraper) GetEmptyCorrections() map[string]Correction {
 		for _, lessonID := range section.Lessons {
 			// Don't try to correct the same thing twice.
 			if _, exists := corrections[lessonID]; exists {
-				addParent(corrections, lessonID, parentID)
-			} else if lesson, exists := cleaner.Site.Lessons[lessonID]; exists {
-				if len(lesson.Pdf) == 0 && len(lesson.Audio) == 0 {
-					corrections[lessonID] = cleaner.getPossibleMatches(lessonID, parentID)
+				continue
+			}
+
+			if lesson, exists := cleaner.Site.Lessons[lessonID]; exists {
+				if len(lesson.Pdf) == 0 && len(lesson.Audio) == 0 && len(lesson.Video) == 0 {
+					corrections[lessonID] = cleaner.getPossibleMatches(lessonID)
 				}
 			}
 		}
@@ -120,18 +139,24 @@ func (cleaner *PostScraper) GetEmptyCorrections() map[string]Correction {
 // applyFix fixes up the site based on the correction. If the correction is executed, marked as such.
 func (cleaner *PostScraper) applyFix(badID string, correction *Correction) {
 	if len(correction.Guesses) > 0 && (correction.IsConfirmed || correction.Is404) {
-		if _, exists := cleaner.Site.Sections[badID]; exists {
-			delete(cleaner.Site.Sections, badID)
-		}
+		cleaner.Site.deleteSection(badID)
 
 		if _, exists := cleaner.Site.Lessons[badID]; exists {
 			delete(cleaner.Site.Lessons, badID)
 		}
 
-		for sectionID, section := range cleaner.Site.Sections {
+		// Only the sections which reference the bad ID need to be fixed.
+		for _, sectionID := range cleaner.Site.Parents(badID) {
+			section, exists := cleaner.Site.Sections[sectionID]
+			if !exists {
+				continue
+			}
+
 			// Keep track of the good sections.
 			// "sectionIDs" which actually reference lessons aren't added.
 			goodSections := make([]string, 0, len(section.Sections))
+			// The lessons are copied, so that the index can find the old ones.
+			section.Lessons = append([]string{}, section.Lessons...)
 
 			for _, subSectionID := range section.Sections {
 				if subSectionID == badID {
@@ -153,20 +178,23 @@ func (cleaner *PostScraper) applyFix(badID string, correction *Correction) {
 			}
 
 			section.Sections = goodSections
-			cleaner.Site.Sections[sectionID] = section
+			cleaner.Site.setSection(sectionID, section)
 		}
 
 		correction.WasCorrected = true
 	}
 }
 
-// Create corrections for a parents reference to a bad ID.
-func (cleaner *PostScraper) getPossibleMatches(id, parentID string) Correction {
+// Create corrections for the references to a bad ID.
+func (cleaner *PostScraper) getPossibleMatches(id string) Correction {
 	correction := Correction{
-		Parents: []string{parentID},
+		Parents: cleaner.Site.Parents(id),
 	}
 
-	if response, err := http.Head(id); err == nil {
+	client := clientFor(cleaner.Transport)
+
+	if response, err := client.Head(id); err == nil {
+		response.Body.Close()
 		if response.StatusCode == http.StatusNotFound {
 			correction.Is404 = true
 		}
@@ -175,19 +203,20 @@ func (cleaner *PostScraper) getPossibleMatches(id, parentID string) Correction {
 	correction.Guesses, correction.Source = cleaner.getPossibleIdsFromSite(id)
 
 	if correction.Guesses != nil {
-		doc1, err := goquery.NewDocument(id)
+		doc1, err := getDocument(client, id)
 		if err != nil {
-			fmt.Println("Error in get pos: ", err)
+			cleaner.addUncheckedCorrection(id, id, err)
 			return correction
 		}
-		doc2, err := goquery.NewDocument(correction.Guesses[0])
+		doc2, err := getDocument(client, correction.Guesses[0])
 		if err != nil {
-			fmt.Println("Error in get pos: ", err)
+			cleaner.addUncheckedCorrection(id, correction.Guesses[0], err)
 			return correction
 		}
 
-		content1 := doc1.Find("#main_container")
-		content2 := doc2.Find("#main_container")
+		mainContent := cleaner.Profile.withDefaults().MainContent
+		content1 := doc1.Find(mainContent)
+		content2 := doc2.Find(mainContent)
 
 		html1, _ := content1.Html()
 		html2, _ := content2.Html()
@@ -200,6 +229,16 @@ func (cleaner *PostScraper) getPossibleMatches(id, parentID string) Correction {
 	return correction
 }
 
+// Records that the correction of the bad ID couldn't be checked, because the
+// page at the given URL couldn't be loaded.
+func (cleaner *PostScraper) addUncheckedCorrection(badID, url string, err error) {
+	cleaner.Problems = append(cleaner.Problems, Problem{
+		Kind:    UncheckedCorrection,
+		URL:     url,
+		Message: "Couldn't check the correction of " + badID + ": " + err.Error(),
+	})
+}
+
 // Searches lessons and sections for matching IDs.
 func (cleaner *PostScraper) getPossibleIdsFromSite(id string) ([]string, DataType) {
 
@@ -243,20 +282,14 @@ func isPossibleMatch(badID, testID string) bool {
 	return false
 }
 
-func getBody(url string) string {
-	response, err := http.Get(url)
-
+// getDocument loads the page at the given URL.
+func getDocument(client *http.Client, url string) (*goquery.Document, error) {
+	response, err := client.Get(url)
 	if err != nil {
-		return ""
+		return nil, err
 	}
 
 	defer response.Body.Close()
 
-	body, err := ioutil.ReadAll(response.Body)
-
-	if err != nil {
-		return ""
-	}
-
-	return string(body)
+	return goquery.NewDocumentFromReader(response.Body)
 }
@@ -0,0 +1,36 @@
+package insidescraper
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestUncheckedCorrection(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html><body>Content</body></html>"))
+	}))
+	defer server.Close()
+
+	bad := server.URL + "/bad"
+	good := server.URL + "/bad-1"
+	cleaner := PostScraper{
+		Site: Site{
+			Sections: map[string]SiteSection{
+				"top": {SiteData: &SiteData{}, ID: "top", Sections: []string{bad}},
+				good:  {SiteData: &SiteData{}, ID: good, Lessons: []string{"1"}},
+			},
+			Lessons: map[string]Lesson{},
+		},
+		Transport: &failingTransport{Fail: "/bad"},
+	}
+
+	cleaner.FixSite()
+
+	if len(cleaner.Problems) != 1 || cleaner.Problems[0].Kind != UncheckedCorrection || cleaner.Problems[0].URL != bad {
+		t.Fatalf("Expected the correction of %s to be unchecked, got %+v", bad, cleaner.Problems)
+	}
+	if correction := cleaner.Missing[bad]; correction.IsConfirmed || correction.WasCorrected {
+		t.Errorf("Expected the unchecked correction not to be applied, got %+v", correction)
+	}
+}
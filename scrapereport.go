@@ -0,0 +1,146 @@
+package insidescraper
+
+import (
+	"sort"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// ProblemKind is the kind of problem which was found while scraping.
+type ProblemKind string
+
+const (
+	// UnparseableRow is a table row which is neither a lesson nor a section.
+	UnparseableRow ProblemKind = "unparseable-row"
+	// MissingHref is a section whose title isn't a link.
+	MissingHref ProblemKind = "missing-href"
+	// MissingMediaSource is a link in a lesson which isn't to audio or a PDF.
+	MissingMediaSource ProblemKind = "missing-media-source"
+	// FailedHead is a link which couldn't be followed to where it leads.
+	FailedHead ProblemKind = "failed-head"
+	// HTTPError is a page which couldn't be visited.
+	HTTPError ProblemKind = "http-error"
+	// PdfWithoutSection is a PDF which was found outside of any section.
+	PdfWithoutSection ProblemKind = "pdf-without-section"
+	// LessonIDCollision is a lesson which got the same ID as another lesson.
+	LessonIDCollision ProblemKind = "lesson-id-collision"
+	// CheckpointError is a checkpoint which couldn't be saved.
+	CheckpointError ProblemKind = "checkpoint-error"
+	// DuplicateSection is a section which references other sections, which was
+	// already created from somewhere else.
+	DuplicateSection ProblemKind = "duplicate-section"
+	// MissingHereURL is a "here" link in a description which doesn't have a URL.
+	MissingHereURL ProblemKind = "missing-here-url"
+	// ImageDownloadError is an image which couldn't be downloaded.
+	ImageDownloadError ProblemKind = "image-download-error"
+	// MediaEnrichmentError is media whose details couldn't be found.
+	MediaEnrichmentError ProblemKind = "media-enrichment-error"
+	// UncheckedCorrection is a correction which couldn't be checked, because one
+	// of the pages couldn't be loaded.
+	UncheckedCorrection ProblemKind = "unchecked-correction"
+)
+
+// Problem is something which went wrong while scraping.
+type Problem struct {
+	Kind ProblemKind
+	// URL is the page or link which the problem is about.
+	URL string
+	// Parent is the ID of the section the problem was found in.
+	Parent string
+	// Excerpt is the HTML where the problem was found, if there is any.
+	Excerpt string
+	Message string
+}
+
+// Error describes the problem, so that it can be used as an error.
+func (problem Problem) Error() string {
+	message := string(problem.Kind) + ": " + problem.Message
+	if problem.URL != "" {
+		message += " (" + problem.URL + ")"
+	}
+	if problem.Parent != "" {
+		message += "\nParent: " + problem.Parent
+	}
+	return message
+}
+
+// ScrapeReport holds every problem which was found while scraping.
+type ScrapeReport struct {
+	Problems []Problem
+	// In strict mode, the scrape stops at the first problem.
+	strict bool
+	first  *Problem
+	lock   sync.Mutex
+}
+
+// add records the problem. Nothing is recorded in a nil report.
+func (report *ScrapeReport) add(problem Problem) {
+	if report == nil {
+		return
+	}
+
+	report.lock.Lock()
+	report.Problems = append(report.Problems, problem)
+	if report.first == nil {
+		report.first = &problem
+	}
+	report.lock.Unlock()
+}
+
+// stopped checks if the scrape should stop, because it's in strict mode and
+// there was a problem.
+func (report *ScrapeReport) stopped() bool {
+	if report == nil {
+		return false
+	}
+
+	report.lock.Lock()
+	defer report.lock.Unlock()
+
+	return report.strict && report.first != nil
+}
+
+// sort puts the problems in a stable order, because pages can be scraped in
+// any order.
+func (report *ScrapeReport) sort() {
+	sort.SliceStable(report.Problems, func(i, j int) bool {
+		a, b := report.Problems[i], report.Problems[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Parent != b.Parent {
+			return a.Parent < b.Parent
+		}
+		return a.URL < b.URL
+	})
+}
+
+// Count returns how many problems of the given kind were found.
+func (report *ScrapeReport) Count(kind ProblemKind) int {
+	count := 0
+	for _, problem := range report.Problems {
+		if problem.Kind == kind {
+			count++
+		}
+	}
+	return count
+}
+
+// The most HTML which is kept in an excerpt.
+const maxExcerptLength = 500
+
+// excerpt gets the HTML of the selection, cut down to a reasonable length.
+func excerpt(selection *goquery.Selection) string {
+	html, _ := goquery.OuterHtml(selection)
+	if len(html) > maxExcerptLength {
+		// Cut at the start of a character, so that the excerpt is valid UTF-8.
+		end := maxExcerptLength
+		for end > 0 && !utf8.RuneStart(html[end]) {
+			end--
+		}
+		html = html[:end] + "..."
+	}
+	return html
+}
@@ -0,0 +1,25 @@
+package insidescraper
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestExcerptIsValidUTF8(t *testing.T) {
+	// Hebrew letters are two bytes each, so one of them is cut in the middle.
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader("<p>" + strings.Repeat("שיעור", 200) + "</p>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text := excerpt(doc.Find("p"))
+	if !utf8.ValidString(text) {
+		t.Errorf("Expected the excerpt to be valid UTF-8, got %q", text)
+	}
+	if !strings.HasSuffix(text, "...") || len(text) > maxExcerptLength+len("...") {
+		t.Errorf("Expected the excerpt to be cut down, got %d bytes", len(text))
+	}
+}
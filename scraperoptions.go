@@ -0,0 +1,88 @@
+package insidescraper
+
+import (
+	"net/http"
+	"time"
+)
+
+// ScraperOptions configures where InsideScraper scrapes from and how it finds
+// content on the pages. Any option which isn't set falls back to its default.
+type ScraperOptions struct {
+	// BaseURL is the page scraping starts from, if no URL is passed to Scrape.
+	// If it isn't the root of the site, only that branch of the site is scraped.
+	BaseURL string
+	// AllowedDomains are the only domains which will be visited. "Here" links in
+	// section descriptions are only followed if they point to one of these.
+	AllowedDomains []string
+	UserAgent      string
+	// Profile holds the selectors which find content on the site.
+	Profile SiteProfile
+	// Transport makes all the scraper's requests, including the ones which
+	// find where links redirect to. If nil, http.DefaultTransport is used.
+	// Set it to a RecordingTransport or ReplayTransport to record or replay a scrape.
+	Transport http.RoundTripper
+	// Parallelism is how many pages may be requested at once. The site comes out
+	// the same however many there are. If it's 0 or 1, pages are requested one at a time.
+	Parallelism int
+	// CheckpointPath is where the state of the scrape is saved every
+	// CheckpointInterval (by default, every minute), so that it can be resumed.
+	// If it's empty, no checkpoints are saved.
+	CheckpointPath     string
+	CheckpointInterval time.Duration
+	// Resume continues the scrape from the checkpoint at CheckpointPath, if
+	// there is one. Pages which were already finished aren't visited again.
+	Resume bool
+	// FallbackImage is used for a top level section which doesn't have an image.
+	FallbackImage string
+	// ImageDir is where the images of the top level sections are downloaded to.
+	// If it's empty, they aren't downloaded.
+	ImageDir string
+	// Strict stops the scrape at the first problem, and returns it as the error.
+	// Otherwise, problems are recorded in the report and skipped.
+	Strict bool
+	// SortAudio sorts the audio of each lesson by the ordinals in their titles,
+	// because the site sometimes lists classes out of order.
+	SortAudio bool
+	// ResolveDescriptionLinks follows the links in descriptions which aren't
+	// already the URL of a section or lesson, to find if they redirect to one.
+	// Each one costs a HEAD request after the crawl, unless URLResolver already
+	// knows where it leads, so it's off by default.
+	ResolveDescriptionLinks bool
+	// URLResolver finds where links lead to. If nil, an in memory RedirectCache
+	// which uses Transport is created.
+	URLResolver URLResolver
+}
+
+// DefaultScraperOptions returns the options for scraping insidechassidus.
+func DefaultScraperOptions() ScraperOptions {
+	return ScraperOptions{
+		BaseURL:        "https://insidechassidus.org/",
+		AllowedDomains: []string{"insidechassidus.org"},
+		UserAgent:      "inside-scraper",
+		Profile:        DefaultSiteProfile(),
+	}
+}
+
+// withDefaults fills in every unset option with its default.
+func (options ScraperOptions) withDefaults() ScraperOptions {
+	defaults := DefaultScraperOptions()
+
+	if options.BaseURL == "" {
+		options.BaseURL = defaults.BaseURL
+	}
+	if len(options.AllowedDomains) == 0 {
+		options.AllowedDomains = defaults.AllowedDomains
+	}
+	if options.UserAgent == "" {
+		options.UserAgent = defaults.UserAgent
+	}
+	if options.CheckpointInterval == 0 {
+		options.CheckpointInterval = time.Minute
+	}
+	if options.URLResolver == nil {
+		options.URLResolver, _ = NewRedirectCache("", options.Transport)
+	}
+	options.Profile = options.Profile.withDefaults()
+
+	return options
+}
@@ -0,0 +1,265 @@
+package insidescraper
+
+// SectionGraph describes the shape of the section hierarchy. The site data is
+// a graph, not a tree: a section can be in a few sections, and sections can
+// even contain each other.
+type SectionGraph struct {
+	// Cycles are the loops of sections: every path which leads back to where it
+	// started without going through a section twice. Each one starts from its
+	// section which is first in the search order (the top level first, and then
+	// the rest sorted by ID), and ends with the section which leads back to it.
+	Cycles [][]string
+	// SharedSections are the sections which are in more than one section, with
+	// their parents.
+	SharedSections map[string][]string
+	// MaxDepth is how many sections are on the longest path from the top level,
+	// not counting the links which close cycles.
+	MaxDepth int
+	// Unreachable are the sections which can't be reached from the top level.
+	Unreachable []string
+}
+
+// SectionLink is a section's reference to one of its sections.
+type SectionLink struct {
+	Parent string
+	Child  string
+}
+
+// AnalyzeSections describes the section hierarchy.
+func (site *Site) AnalyzeSections() SectionGraph {
+	graph := SectionGraph{
+		Cycles:         site.findCycles(),
+		SharedSections: make(map[string][]string),
+		MaxDepth:       site.getMaxDepth(site.findBackLinks()),
+		Unreachable:    make([]string, 0),
+	}
+
+	for id := range site.Sections {
+		if parents := site.sectionParents(id); len(parents) > 1 {
+			graph.SharedSections[id] = parents
+		}
+	}
+
+	reachable := site.getShortestPaths()
+	for _, id := range site.sortedSectionIDs() {
+		if _, isReachable := reachable[id]; !isReachable {
+			graph.Unreachable = append(graph.Unreachable, id)
+		}
+	}
+
+	return graph
+}
+
+// BreakCycles removes the links which close cycles, so that the counter and the
+// resolver don't go around them. The same links are removed every time, because
+// the sections are searched in order: the top level first, and then the rest
+// sorted by ID. It returns the links which were removed.
+func (site *Site) BreakCycles() []SectionLink {
+	backLinks := site.findBackLinks()
+
+	for _, link := range backLinks {
+		parent := site.Sections[link.Parent]
+		sections := make([]string, 0, len(parent.Sections))
+		for _, id := range parent.Sections {
+			if id != link.Child {
+				sections = append(sections, id)
+			}
+		}
+		parent.Sections = sections
+		site.setSection(link.Parent, parent)
+	}
+
+	return backLinks
+}
+
+// findBackLinks searches the sections depth first, and finds every link to a
+// section which is still being searched. Each one closes a cycle. Without them,
+// there aren't any cycles.
+func (site *Site) findBackLinks() []SectionLink {
+	const (
+		searching = 1
+		searched  = 2
+	)
+
+	states := make(map[string]int, len(site.Sections))
+	backLinks := make([]SectionLink, 0)
+
+	var search func(id string)
+	search = func(id string) {
+		states[id] = searching
+
+		for _, childID := range site.childSections(id) {
+			switch states[childID] {
+			case searching:
+				backLinks = append(backLinks, SectionLink{Parent: id, Child: childID})
+			case 0:
+				search(childID)
+			}
+		}
+
+		states[id] = searched
+	}
+
+	for _, id := range site.searchOrder() {
+		if states[id] == 0 {
+			search(id)
+		}
+	}
+
+	return backLinks
+}
+
+// findCycles finds every cycle, with Johnson's algorithm. The cycles through
+// each section are found from it, in the search order, going only through the
+// sections after it, so that every cycle is found once. A section which didn't
+// lead back is blocked until a section it leads to does, so that dead ends
+// aren't searched again.
+func (site *Site) findCycles() [][]string {
+	order := site.searchOrder()
+	positions := make(map[string]int, len(order))
+	for i, id := range order {
+		positions[id] = i
+	}
+
+	cycles := make([][]string, 0)
+
+	for position, start := range order {
+		blocked := make(map[string]bool)
+		// blockedBy has the blocked sections which lead to each section.
+		blockedBy := make(map[string]map[string]bool)
+		stack := make([]string, 0)
+
+		var unblock func(id string)
+		unblock = func(id string) {
+			blocked[id] = false
+			for waitingID := range blockedBy[id] {
+				delete(blockedBy[id], waitingID)
+				if blocked[waitingID] {
+					unblock(waitingID)
+				}
+			}
+		}
+
+		var search func(id string) bool
+		search = func(id string) bool {
+			found := false
+			stack = append(stack, id)
+			blocked[id] = true
+
+			children := make([]string, 0)
+			for _, childID := range site.childSections(id) {
+				if positions[childID] >= position {
+					children = append(children, childID)
+				}
+			}
+
+			for _, childID := range children {
+				if childID == start {
+					cycles = append(cycles, append([]string{}, stack...))
+					found = true
+				} else if !blocked[childID] && search(childID) {
+					found = true
+				}
+			}
+
+			if found {
+				unblock(id)
+			} else {
+				for _, childID := range children {
+					if blockedBy[childID] == nil {
+						blockedBy[childID] = make(map[string]bool, 1)
+					}
+					blockedBy[childID][id] = true
+				}
+			}
+
+			stack = stack[:len(stack)-1]
+			return found
+		}
+
+		search(start)
+	}
+
+	return cycles
+}
+
+// childSections gets the sections which the section contains, in order,
+// without missing sections or repeats.
+func (site *Site) childSections(id string) []string {
+	children := make([]string, 0, len(site.Sections[id].Sections))
+	linked := make(map[string]bool, len(site.Sections[id].Sections))
+
+	for _, childID := range site.Sections[id].Sections {
+		if _, exists := site.Sections[childID]; !exists || linked[childID] {
+			continue
+		}
+		linked[childID] = true
+		children = append(children, childID)
+	}
+
+	return children
+}
+
+// searchOrder is the order to search the sections in: the top level first, and
+// then the rest, sorted. Each section is in it once.
+func (site *Site) searchOrder() []string {
+	order := make([]string, 0, len(site.Sections))
+	isOrdered := make(map[string]bool, len(site.Sections))
+
+	for _, item := range site.TopLevel {
+		if _, exists := site.Sections[item.ID]; exists && !isOrdered[item.ID] {
+			isOrdered[item.ID] = true
+			order = append(order, item.ID)
+		}
+	}
+	for _, id := range site.sortedSectionIDs() {
+		if !isOrdered[id] {
+			order = append(order, id)
+		}
+	}
+
+	return order
+}
+
+// getMaxDepth finds how many sections are on the longest path from the top
+// level, ignoring the given links.
+func (site *Site) getMaxDepth(ignore []SectionLink) int {
+	ignored := make(map[SectionLink]bool, len(ignore))
+	for _, link := range ignore {
+		ignored[link] = true
+	}
+
+	depths := make(map[string]int, len(site.Sections))
+
+	var getDepth func(id string) int
+	getDepth = func(id string) int {
+		if depth, exists := depths[id]; exists {
+			return depth
+		}
+
+		depth := 1
+		for _, childID := range site.Sections[id].Sections {
+			if _, exists := site.Sections[childID]; !exists || ignored[SectionLink{Parent: id, Child: childID}] {
+				continue
+			}
+			if childDepth := getDepth(childID) + 1; childDepth > depth {
+				depth = childDepth
+			}
+		}
+
+		depths[id] = depth
+		return depth
+	}
+
+	maxDepth := 0
+	for _, item := range site.TopLevel {
+		if _, exists := site.Sections[item.ID]; !exists {
+			continue
+		}
+		if depth := getDepth(item.ID); depth > maxDepth {
+			maxDepth = depth
+		}
+	}
+
+	return maxDepth
+}
@@ -0,0 +1,119 @@
+package insidescraper
+
+import (
+	"reflect"
+	"testing"
+)
+
+func getGraphSite() Site {
+	section := func(id string, sections ...string) SiteSection {
+		return SiteSection{SiteData: &SiteData{}, ID: id, Sections: sections}
+	}
+
+	return Site{
+		TopLevel: []TopItem{{ID: "top"}},
+		Sections: map[string]SiteSection{
+			"top": section("top", "a", "b"),
+			"a":   section("a", "c"),
+			"b":   withLessons(section("b", "c", "b"), "2"),
+			"c":   section("c", "d", "a"),
+			"d":   withLessons(section("d"), "1"),
+			// Only reachable from each other.
+			"x": section("x", "y"),
+			"y": section("y", "x"),
+		},
+		Lessons: map[string]Lesson{
+			"1": {SiteData: &SiteData{}, ID: "1", Audio: []Media{{SiteData: &SiteData{}, Source: "1.mp3"}}},
+			"2": {SiteData: &SiteData{}, ID: "2", Audio: []Media{{SiteData: &SiteData{}, Source: "2a.mp3"}, {SiteData: &SiteData{}, Source: "2b.mp3"}}},
+		},
+	}
+}
+
+func withLessons(section SiteSection, lessons ...string) SiteSection {
+	section.Lessons = lessons
+	return section
+}
+
+func TestAnalyzeSections(t *testing.T) {
+	site := getGraphSite()
+	graph := site.AnalyzeSections()
+
+	expected := SectionGraph{
+		Cycles: [][]string{{"a", "c"}, {"b"}, {"x", "y"}},
+		SharedSections: map[string][]string{
+			"a": {"c", "top"},
+			"b": {"b", "top"},
+			"c": {"a", "b"},
+		},
+		MaxDepth:    4,
+		Unreachable: []string{"x", "y"},
+	}
+
+	if !reflect.DeepEqual(graph, expected) {
+		t.Errorf("Expected\n%+v\ngot\n%+v", expected, graph)
+	}
+}
+
+func TestFindEveryCycle(t *testing.T) {
+	section := func(id string, sections ...string) SiteSection {
+		return SiteSection{SiteData: &SiteData{}, ID: id, Sections: sections}
+	}
+
+	// Searching depth first misses a-c, because by the time the link from a to
+	// c is followed, c was already searched from b.
+	site := Site{
+		TopLevel: []TopItem{{ID: "a"}},
+		Sections: map[string]SiteSection{
+			"a": section("a", "b", "c"),
+			"b": section("b", "c"),
+			"c": section("c", "a", "b"),
+		},
+	}
+
+	expected := [][]string{{"a", "b", "c"}, {"a", "c"}, {"b", "c"}}
+	if cycles := site.AnalyzeSections().Cycles; !reflect.DeepEqual(cycles, expected) {
+		t.Errorf("Expected the cycles %v, got %v", expected, cycles)
+	}
+}
+
+func TestBreakCycles(t *testing.T) {
+	site := getGraphSite()
+	removed := site.BreakCycles()
+
+	expected := []SectionLink{{Parent: "c", Child: "a"}, {Parent: "b", Child: "b"}, {Parent: "y", Child: "x"}}
+	if !reflect.DeepEqual(removed, expected) {
+		t.Errorf("Expected to remove %v, removed %v", expected, removed)
+	}
+
+	if graph := site.AnalyzeSections(); len(graph.Cycles) != 0 {
+		t.Errorf("Expected no cycles, got %v", graph.Cycles)
+	}
+
+	if !reflect.DeepEqual(site.Sections["c"].Sections, []string{"d"}) {
+		t.Errorf("Expected c to only contain d, got %v", site.Sections["c"].Sections)
+	}
+
+	// Breaking the cycles again doesn't change anything.
+	if removed := site.BreakCycles(); len(removed) != 0 {
+		t.Errorf("Expected nothing to be removed, removed %v", removed)
+	}
+
+	// Without the cycles, counting and resolving finish, and each lesson is
+	// counted once on every path to it.
+	counter := MakeCounter(&site)
+	counter.CountLessons()
+
+	expectedCounts := map[string]int{"top": 4, "a": 1, "b": 3, "c": 1, "d": 1}
+	for id, count := range expectedCounts {
+		if site.Sections[id].AudioCount != count {
+			t.Errorf("Expected %s to have %d classes, got %d", id, count, site.Sections[id].AudioCount)
+		}
+	}
+
+	resolver := SectionResolver{Site: site}
+	resolver.ResolveSite()
+
+	if top := resolver.ResolvedSite.Sections["top"]; top.AudioCount != 4 {
+		t.Errorf("Expected the resolved top section to have 4 classes, got %d", top.AudioCount)
+	}
+}
@@ -0,0 +1,127 @@
+package insidescraper
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/gocolly/colly"
+)
+
+// SectionPage describes the page a section was scraped from.
+type SectionPage struct {
+	// URL is the page's address, after any redirects.
+	URL string
+	// CanonicalURL is the address which the page gives for itself, if it gives one.
+	CanonicalURL string `json:",omitempty"`
+	// Title is the page's <title>.
+	Title string `json:",omitempty"`
+	// OpenGraph holds the page's og: metadata, by property without the prefix,
+	// eg "title" and "image".
+	OpenGraph map[string]string `json:",omitempty"`
+	// Breadcrumbs are the page's breadcrumb trail, starting from the home page.
+	Breadcrumbs []Breadcrumb `json:",omitempty"`
+}
+
+// Breadcrumb is one step of a breadcrumb trail. The current page usually
+// doesn't have a URL.
+type Breadcrumb struct {
+	Title string
+	URL   string `json:",omitempty"`
+}
+
+// getSectionPage reads the metadata and breadcrumbs of the page.
+func (scraper *InsideScraper) getSectionPage(e *colly.HTMLElement) *SectionPage {
+	page := &SectionPage{
+		URL:   e.Request.URL.String(),
+		Title: strings.TrimSpace(e.ChildText("head > title")),
+	}
+
+	if canonical := e.ChildAttr(`link[rel="canonical"]`, "href"); canonical != "" {
+		page.CanonicalURL = e.Request.AbsoluteURL(canonical)
+	}
+
+	e.ForEach(`meta[property^="og:"]`, func(_ int, meta *colly.HTMLElement) {
+		if page.OpenGraph == nil {
+			page.OpenGraph = make(map[string]string, 5)
+		}
+		page.OpenGraph[strings.TrimPrefix(meta.Attr("property"), "og:")] = meta.Attr("content")
+	})
+
+	e.ForEach(scraper.Options.Profile.Breadcrumb, func(_ int, crumb *colly.HTMLElement) {
+		if scraper.isOnMobile(crumb.DOM) {
+			return
+		}
+
+		title := strings.TrimSpace(crumb.Text)
+		if title == "" {
+			return
+		}
+
+		link := crumb.DOM.Find("a[href]").AddBack().Filter("a[href]").First()
+		breadcrumb := Breadcrumb{Title: title}
+		if href, exists := link.Attr("href"); exists {
+			breadcrumb.URL = e.Request.AbsoluteURL(href)
+		}
+
+		page.Breadcrumbs = append(page.Breadcrumbs, breadcrumb)
+	})
+
+	return page
+}
+
+// BreadcrumbMismatch is a section whose breadcrumbs say it's in a section which
+// doesn't contain it on the site.
+type BreadcrumbMismatch struct {
+	SectionID string
+	// BreadcrumbParent is the section before it in its breadcrumb trail, and
+	// Parents are the sections which really contain it.
+	BreadcrumbParent string
+	Parents          []string
+}
+
+// CheckBreadcrumbs compares the hierarchy of the sections with the site's own
+// navigation: the section before each one in its breadcrumbs should be one of
+// its parents. Links in the breadcrumbs are resolved with the resolver, if it
+// isn't nil. Breadcrumbs which don't lead to a known section are ignored.
+func (site *Site) CheckBreadcrumbs(resolver URLResolver) []BreadcrumbMismatch {
+	mismatches := make([]BreadcrumbMismatch, 0)
+
+	for id, section := range site.Sections {
+		if section.Page == nil {
+			continue
+		}
+
+		// The last crumb is usually the page itself.
+		crumbs := section.Page.Breadcrumbs
+		if len(crumbs) > 0 && (crumbs[len(crumbs)-1].URL == "" || crumbs[len(crumbs)-1].URL == section.Page.URL) {
+			crumbs = crumbs[:len(crumbs)-1]
+		}
+		if len(crumbs) == 0 || crumbs[len(crumbs)-1].URL == "" {
+			continue
+		}
+
+		parentID := crumbs[len(crumbs)-1].URL
+		if _, exists := site.Sections[parentID]; !exists && resolver != nil {
+			if resolved, err := resolver.Resolve(parentID); err == nil {
+				parentID = resolved
+			}
+		}
+		if _, exists := site.Sections[parentID]; !exists {
+			continue
+		}
+
+		if parents := site.sectionParents(id); !site.isSectionParent(id, parentID) {
+			mismatches = append(mismatches, BreadcrumbMismatch{
+				SectionID:        id,
+				BreadcrumbParent: parentID,
+				Parents:          parents,
+			})
+		}
+	}
+
+	sort.Slice(mismatches, func(i, j int) bool {
+		return mismatches[i].SectionID < mismatches[j].SectionID
+	})
+
+	return mismatches
+}
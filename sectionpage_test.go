@@ -0,0 +1,61 @@
+package insidescraper
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSectionPage(t *testing.T) {
+	server := newFakeSite()
+	defer server.Close()
+
+	scraper := InsideScraper{Options: fakeSiteOptions(server)}
+	if _, err := scraper.Scrape(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &SectionPage{
+		URL:          server.URL + "/section-a/sub",
+		CanonicalURL: server.URL + "/section-a/sub",
+		Title:        "Sub Section | Fake Site",
+		OpenGraph: map[string]string{
+			"title": "Sub Section",
+			"image": server.URL + "/images/sub.png",
+		},
+		Breadcrumbs: []Breadcrumb{
+			{Title: "Home", URL: server.URL + "/"},
+			{Title: "Section A", URL: server.URL + "/section-a"},
+			{Title: "Sub Section"},
+		},
+	}
+
+	if page := scraper.Site.Sections[server.URL+"/section-a/sub"].Page; !reflect.DeepEqual(page, expected) {
+		t.Errorf("Expected the page %+v, got %+v", expected, page)
+	}
+
+	if page := scraper.Site.Sections[server.URL+"/section-a"].Page; page == nil || page.URL != server.URL+"/section-a" {
+		t.Errorf("Expected section A to have its page, got %+v", page)
+	}
+
+	if mismatches := scraper.Site.CheckBreadcrumbs(nil); len(mismatches) != 0 {
+		t.Errorf("Expected the breadcrumbs to match, got %+v", mismatches)
+	}
+}
+
+func TestCheckBreadcrumbs(t *testing.T) {
+	site := Site{
+		Sections: map[string]SiteSection{
+			"/a": {SiteData: &SiteData{}, ID: "/a", Sections: []string{"/a/sub"}},
+			"/b": {SiteData: &SiteData{}, ID: "/b"},
+			"/a/sub": {SiteData: &SiteData{}, ID: "/a/sub", Page: &SectionPage{
+				URL:         "/a/sub",
+				Breadcrumbs: []Breadcrumb{{Title: "B", URL: "/b"}, {Title: "Sub", URL: "/a/sub"}},
+			}},
+		},
+	}
+
+	expected := []BreadcrumbMismatch{{SectionID: "/a/sub", BreadcrumbParent: "/b", Parents: []string{"/a"}}}
+	if mismatches := site.CheckBreadcrumbs(nil); !reflect.DeepEqual(mismatches, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, mismatches)
+	}
+}
@@ -13,6 +13,14 @@ type ResolvedSection struct {
 	// AudioCount contains the total number of audio classes contained in this section,
 	// including all descendant sections.
 	AudioCount int
+	// AudioDuration is the total length in seconds of those classes, if the
+	// media was enriched.
+	AudioDuration float64 `json:",omitempty"`
+	// VideoCount is the total number of videos in this section, including all
+	// descendant sections.
+	VideoCount int `json:",omitempty"`
+	// Page describes the page the section was scraped from, if it has one.
+	Page *SectionPage `json:",omitempty"`
 }
 
 // ContentReference can refer to any of section, lesson, or media
@@ -79,8 +87,8 @@ func (resolver *SectionResolver) ResolveSection(sectionID string) *ResolvingItem
 
 	section := resolver.Site.Sections[sectionID]
 
-	if section.AudioCount == 1 {
-		if len(section.Lessons) > 0 {
+	if section.AudioCount == 1 && section.VideoCount == 0 {
+		if len(section.Lessons) > 0 && len(resolver.Site.Lessons[section.Lessons[0]].Audio) > 0 {
 			lesson := resolver.Site.Lessons[section.Lessons[0]]
 			media := resolver.ResolveMedia(lesson.Audio[0], lesson.SiteData)
 			return &ResolvingItem{
@@ -115,11 +123,14 @@ func (resolver *SectionResolver) ResolveSection(sectionID string) *ResolvingItem
 	// Otherwise, reference the lesson in parent, and add lesson to resolved output.
 
 	resolver.ResolvedSite.Sections[sectionID] = ResolvedSection{
-		SiteData:   section.SiteData,
-		ID:         sectionID,
-		AudioCount: section.AudioCount,
-		Content:    make([]ContentReference, 0),
-		Audio:      make(map[string]Media),
+		SiteData:      section.SiteData,
+		ID:            sectionID,
+		AudioCount:    section.AudioCount,
+		AudioDuration: section.AudioDuration,
+		VideoCount:    section.VideoCount,
+		Page:          section.Page,
+		Content:       make([]ContentReference, 0),
+		Audio:         make(map[string]Media),
 	}
 
 	// Incorporate all the lessons. If its a single audio, is absorbed into parent section.
@@ -141,7 +152,8 @@ func (resolver *SectionResolver) ResolveSection(sectionID string) *ResolvingItem
 // resolveLessons resolves lesson into reference. If a lesson is just a single media, turned into media.
 func (resolver *SectionResolver) resolveLesson(lessonID string) *ResolvingItem {
 	lesson := resolver.Site.Lessons[lessonID]
-	if len(lesson.Audio) == 1 {
+	// A lesson with videos is always kept as a lesson, so that they aren't lost.
+	if len(lesson.Audio) == 1 && len(lesson.Video) == 0 {
 		audio := resolver.ResolveMedia(lesson.Audio[0], lesson.SiteData)
 		return &ResolvingItem{
 			Type:  MediaType,
@@ -149,7 +161,7 @@ func (resolver *SectionResolver) resolveLesson(lessonID string) *ResolvingItem {
 		}
 	}
 
-	if len(lesson.Audio) == 0 {
+	if len(lesson.Audio) == 0 && len(lesson.Video) == 0 {
 		return &ResolvingItem{
 			Type:  MediaType,
 			Audio: nil,
@@ -236,7 +248,7 @@ func (resolver *SectionResolver) isEverySectionMedia(sectionID string) bool {
 	section := resolver.Site.Sections[sectionID]
 
 	for _, subSection := range section.Sections {
-		if resolver.Site.Sections[subSection].AudioCount > 1 {
+		if resolver.Site.Sections[subSection].AudioCount > 1 || resolver.Site.Sections[subSection].VideoCount > 0 {
 			return false
 		}
 	}
@@ -249,7 +261,7 @@ func (resolver *SectionResolver) isEveryLessonMedia(sectionID string) bool {
 	section := resolver.Site.Sections[sectionID]
 
 	for _, lessonID := range section.Lessons {
-		if len(resolver.Site.Lessons[lessonID].Audio) > 1 {
+		if lesson := resolver.Site.Lessons[lessonID]; len(lesson.Audio) > 1 || len(lesson.Video) > 0 {
 			return false
 		}
 	}
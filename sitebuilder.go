@@ -0,0 +1,283 @@
+package insidescraper
+
+import (
+	"strconv"
+)
+
+// pageItemKind is the kind of thing which was found on a page.
+type pageItemKind int
+
+const (
+	// A link to a top level section, from the main menu.
+	topLevelItem pageItemKind = iota
+	// A row which is a section.
+	sectionItem
+	// A lesson, from a row or from outside a table.
+	lessonItem
+	// A PDF which belongs to the page's section.
+	pdfItem
+	// The metadata of the page, which describes its section.
+	pageInfoItem
+)
+
+// pageItem is one thing which was found on a page.
+type pageItem struct {
+	Kind pageItemKind
+	// URL is the URL of a section (after all redirects), or of a PDF.
+	URL string
+	// Image is the image of a top level section.
+	Image string
+	// Title is the title of a section, or the text of a link to a PDF.
+	Title           string
+	Description     string
+	DescriptionHTML string
+	// HereURLs are the sections which are linked to from the section's description.
+	HereURLs []string
+	// URLError is why the section's URL couldn't be found, if it couldn't, and
+	// Excerpt is the HTML which it should have been found in.
+	URLError string
+	Excerpt  string
+	Lesson   *Lesson
+	Page     *SectionPage
+}
+
+// visitsSection checks if the page of the section in this row should be scraped.
+// If the description links to other sections, the row is just a reference to
+// them, unless the description links to the same page as the title.
+func (item pageItem) visitsSection() bool {
+	return item.URLError == "" && item.URL != "" &&
+		(len(item.HereURLs) == 0 || (len(item.HereURLs) == 1 && item.HereURLs[0] == item.URL))
+}
+
+// siteBuilder puts together the site from what was found on each page, depth
+// first from the start page.
+type siteBuilder struct {
+	// pages holds what was found on each page, by the URL it was requested with.
+	pages map[string][]pageItem
+	site  Site
+	// builtPages are the pages which were already added to the site.
+	builtPages map[string]bool
+	report     *ScrapeReport
+}
+
+// buildSite puts together the site from the pages, starting from the given page.
+// If the start page is a section, rootID is its ID. Its title and description
+// are on its parent's page, so it doesn't have them.
+// Problems are added to the report, if there is one.
+func buildSite(pages map[string][]pageItem, startURL, rootID string, report *ScrapeReport) Site {
+	builder := siteBuilder{
+		pages:  pages,
+		report: report,
+		site: Site{
+			Sections: make(map[string]SiteSection, 1000),
+			Lessons:  make(map[string]Lesson, 1000),
+			TopLevel: make([]TopItem, 0, 10),
+		},
+		builtPages: make(map[string]bool, len(pages)),
+	}
+
+	if rootID != "" {
+		builder.site.Sections[rootID] = SiteSection{
+			SiteData: &SiteData{},
+			ID:       rootID,
+			Sections: make([]string, 0, 20),
+			Lessons:  make([]string, 0, 20),
+		}
+	}
+
+	builder.buildPage(startURL, rootID)
+
+	return builder.site
+}
+
+// buildPage adds everything on the page to the site. Like a visit, each page
+// is only used once.
+func (builder *siteBuilder) buildPage(pageURL, sectionID string) {
+	if builder.builtPages[pageURL] {
+		return
+	}
+	builder.builtPages[pageURL] = true
+
+	for _, item := range builder.pages[pageURL] {
+		if builder.report.stopped() {
+			return
+		}
+
+		switch item.Kind {
+		case topLevelItem:
+			builder.buildTopLevel(item)
+		case sectionItem:
+			builder.buildSection(item, sectionID)
+		case lessonItem:
+			builder.addLesson(*item.Lesson, sectionID)
+		case pdfItem:
+			section := builder.site.Sections[sectionID]
+			section.Pdf = append(section.Pdf, Document{
+				URL:   item.URL,
+				Title: item.Title,
+				Page:  pageURL,
+			})
+			builder.site.Sections[sectionID] = section
+		case pageInfoItem:
+			if section, exists := builder.site.Sections[sectionID]; exists {
+				section.Page = item.Page
+				builder.site.Sections[sectionID] = section
+			}
+		}
+	}
+}
+
+func (builder *siteBuilder) buildTopLevel(item pageItem) {
+	sectionID := item.URL
+
+	// If a top level section was already scraped as a sub section, simply
+	// mark it as being a top level section.
+	if _, exists := builder.site.Sections[sectionID]; exists {
+		builder.site.TopLevel = append(builder.site.TopLevel, TopItem{
+			ID:    sectionID,
+			Image: item.Image,
+		})
+		return
+	}
+
+	builder.site.Sections[sectionID] = SiteSection{
+		SiteData: &SiteData{
+			Title: item.Title,
+		},
+		ID:       sectionID,
+		Sections: make([]string, 0, 10),
+	}
+	builder.site.TopLevel = append(builder.site.TopLevel, TopItem{
+		ID:    sectionID,
+		Image: item.Image,
+	})
+
+	builder.buildPage(item.URL, sectionID)
+}
+
+// buildSection adds a section row to its parent section.
+func (builder *siteBuilder) buildSection(item pageItem, parentID string) {
+	// Urls in description are references to sections which are in a different section.
+	// Don't scrape them now, just add that reference to the current section.
+
+	// More than 1: create a sub section.
+	if len(item.HereURLs) > 1 {
+		subSections := make([]string, 0, len(item.HereURLs))
+		for _, url := range item.HereURLs {
+			subSections = append(subSections, getHash(url))
+		}
+
+		currentID := item.URL
+
+		if _, exists := builder.site.Sections[currentID]; exists {
+			builder.report.add(Problem{
+				Kind:    DuplicateSection,
+				URL:     currentID,
+				Parent:  parentID,
+				Message: "Section which references other sections already exists",
+			})
+			return
+		}
+
+		builder.site.Sections[currentID] = SiteSection{
+			SiteData: &SiteData{
+				Title:           item.Title,
+				Description:     item.Description,
+				DescriptionHTML: item.DescriptionHTML,
+			},
+			ID:       currentID,
+			Sections: subSections,
+		}
+
+		parent := builder.site.Sections[parentID]
+		parent.Sections = append(parent.Sections, currentID)
+		builder.site.Sections[parentID] = parent
+
+		return
+	}
+
+	// If there's only 1 referenced: Add it to current section.
+	// If description has same URL as title, then this is a good link and we should follow it.
+	if len(item.HereURLs) == 1 && item.HereURLs[0] != item.URL {
+		parent := builder.site.Sections[parentID]
+		parent.Sections = append(parent.Sections, getHash(item.HereURLs[0]))
+		builder.site.Sections[parentID] = parent
+
+		return
+	}
+
+	if item.URLError != "" || item.URL == "" {
+		builder.report.add(Problem{
+			Kind:    MissingHref,
+			Parent:  parentID,
+			Excerpt: item.Excerpt,
+			Message: "URL not found: " + item.URLError,
+		})
+		return
+	}
+
+	sectionID := getHash(item.URL)
+
+	// Add this section to the parent section.
+	if parentID != "" {
+		parent, _ := builder.site.Sections[parentID]
+		parent.Sections = append(parent.Sections, sectionID)
+		builder.site.Sections[parentID] = parent
+	}
+
+	// If a section is referenced in multiple places and it was already visited,
+	// don't try to create it again; it'll end up making an empty section (because it's URL has
+	// already been scraped), and over-writing the real data.
+	if _, hasKey := builder.site.Sections[sectionID]; hasKey {
+		return
+	}
+
+	/*
+		Load a section and all of it's children.
+	*/
+
+	builder.site.Sections[sectionID] = SiteSection{
+		SiteData: &SiteData{
+			Title:           item.Title,
+			Description:     item.Description,
+			DescriptionHTML: item.DescriptionHTML,
+		},
+		ID:       sectionID,
+		Sections: make([]string, 0, 20),
+		Lessons:  make([]string, 0, 20),
+	}
+
+	builder.buildPage(item.URL, sectionID)
+
+	// // If this section is really a lesson, save that fact for later use.
+	// if err := builder.site.ConvertToLesson(sectionID); err == nil {
+	// 	scraper.sectionLessons[sectionID] = builder.site.Lessons[sectionID].ID
+	// }
+}
+
+// addLesson adds the lesson to the site, and to the given section.
+func (builder *siteBuilder) addLesson(lesson Lesson, sectionID string) {
+	// IDs are derived from the lesson's position and content, so two lessons can
+	// only get the same ID if something odd is going on. Report it, and make
+	// the ID unique so that neither lesson is lost.
+	if _, exists := builder.site.Lessons[lesson.ID]; exists {
+		builder.report.add(Problem{
+			Kind:    LessonIDCollision,
+			Parent:  sectionID,
+			Message: "Lesson ID collision: " + lesson.ID,
+		})
+
+		baseID := lesson.ID
+		for i := 2; exists; i++ {
+			lesson.ID = baseID + "-" + strconv.Itoa(i)
+			_, exists = builder.site.Lessons[lesson.ID]
+		}
+	}
+
+	builder.site.Lessons[lesson.ID] = lesson
+
+	// Append this lesson id to current section.
+	section, _ := builder.site.Sections[sectionID]
+	section.Lessons = append(section.Lessons, lesson.ID)
+	builder.site.Sections[sectionID] = section
+}
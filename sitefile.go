@@ -0,0 +1,220 @@
+package insidescraper
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"runtime/debug"
+	"time"
+)
+
+// SiteFormatVersion is the version of the format which site data is written in.
+// Version 1 is a bare Site, whose PDFs are just URLs. Version 2 is a SiteFile,
+// whose PDFs are Documents.
+const SiteFormatVersion = 2
+
+// modulePath is the path of this package's module, which is used to find its version.
+const modulePath = "github.com/yringler/inside-chassidus-scraper"
+
+// SiteFile is the envelope which site data is saved in. Besides the site, it
+// says where and when the site came from.
+type SiteFile struct {
+	FormatVersion int
+	// ScraperVersion is the version of this package which made the data.
+	ScraperVersion string `json:",omitempty"`
+	// SourceURL is where the site was scraped from.
+	SourceURL string `json:",omitempty"`
+	// StartTime and EndTime are when the scrape started and finished.
+	StartTime *time.Time `json:",omitempty"`
+	EndTime   *time.Time `json:",omitempty"`
+	Site      Site
+}
+
+// Migration upgrades site data from one format version to the next. It works
+// on the decoded JSON, so that it doesn't depend on the current types.
+type Migration func(data map[string]interface{}) (map[string]interface{}, error)
+
+// migrations holds the migration from each version to the next.
+var migrations = map[int]Migration{
+	1: migrateBareSite,
+}
+
+// RegisterMigration adds the migration from the given format version to the next one.
+func RegisterMigration(from int, migration Migration) {
+	migrations[from] = migration
+}
+
+// ScraperVersion gets the version of this package, from the build information
+// of the program which uses it.
+func ScraperVersion() string {
+	info, exists := debug.ReadBuildInfo()
+	if !exists {
+		return ""
+	}
+
+	if info.Main.Path == modulePath {
+		return info.Main.Version
+	}
+	for _, dependency := range info.Deps {
+		if dependency.Path == modulePath {
+			return dependency.Version
+		}
+	}
+
+	return ""
+}
+
+// ReadSite loads site data from a JSON file. Older formats are migrated.
+func ReadSite(path string) (Site, error) {
+	file, err := ReadSiteFile(path)
+	return file.Site, err
+}
+
+// ReadResolvedSite loads a resolved site from a JSON file.
+func ReadResolvedSite(path string) (ResolvedSite, error) {
+	var site ResolvedSite
+
+	jsonText, err := ioutil.ReadFile(path)
+	if err != nil {
+		return site, err
+	}
+
+	if err := decodeStrict(jsonText, &site); err != nil {
+		return site, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return site, nil
+}
+
+// ReadSiteFile loads site data, with its envelope, from a JSON file. Older
+// formats are migrated.
+func ReadSiteFile(path string) (SiteFile, error) {
+	jsonText, err := ioutil.ReadFile(path)
+	if err != nil {
+		return SiteFile{}, err
+	}
+
+	file, err := DecodeSiteFile(jsonText)
+	if err != nil {
+		return file, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return file, nil
+}
+
+// DecodeSiteFile decodes site data in any format version, and migrates it to
+// the current one. Fields which the format doesn't have are an error.
+func DecodeSiteFile(jsonText []byte) (SiteFile, error) {
+	var file SiteFile
+
+	decoder := json.NewDecoder(bytes.NewReader(jsonText))
+	decoder.UseNumber()
+	var data map[string]interface{}
+	if err := decoder.Decode(&data); err != nil {
+		return file, err
+	}
+
+	// Before there was an envelope, the file was just the site.
+	version := 1
+	if rawVersion, exists := data["FormatVersion"]; exists {
+		number, isNumber := rawVersion.(json.Number)
+		parsed, err := number.Int64()
+		if !isNumber || err != nil {
+			return file, fmt.Errorf("invalid format version: %v", rawVersion)
+		}
+		version = int(parsed)
+	}
+
+	if version > SiteFormatVersion {
+		return file, fmt.Errorf("format version %d is newer than this scraper's, %d", version, SiteFormatVersion)
+	}
+
+	for ; version < SiteFormatVersion; version++ {
+		migration, exists := migrations[version]
+		if !exists {
+			return file, fmt.Errorf("no migration from format version %d", version)
+		}
+
+		var err error
+		if data, err = migration(data); err != nil {
+			return file, fmt.Errorf("migrating from format version %d: %v", version, err)
+		}
+	}
+
+	migrated, err := json.Marshal(data)
+	if err != nil {
+		return file, err
+	}
+
+	err = decodeStrict(migrated, &file)
+	return file, err
+}
+
+// WriteSiteFile writes site data in the current format. If the scraper version
+// isn't set, it's set to the version of this package.
+func WriteSiteFile(path string, file SiteFile) error {
+	file.FormatVersion = SiteFormatVersion
+	if file.ScraperVersion == "" {
+		file.ScraperVersion = ScraperVersion()
+	}
+
+	return WriteJSON(path, file)
+}
+
+// WriteJSON writes the given data to a file as indented JSON.
+func WriteJSON(path string, data interface{}) error {
+	jsonOut, err := json.MarshalIndent(data, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, jsonOut, 0644)
+}
+
+// decodeStrict decodes the JSON, failing on fields which v doesn't have.
+func decodeStrict(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
+// migrateBareSite puts a bare site in an envelope, and changes its PDF URLs to documents.
+func migrateBareSite(data map[string]interface{}) (map[string]interface{}, error) {
+	sections, _ := data["Sections"].(map[string]interface{})
+	for _, section := range sections {
+		migratePdfs(section)
+	}
+
+	lessons, _ := data["Lessons"].(map[string]interface{})
+	for _, lesson := range lessons {
+		migratePdfs(lesson)
+
+		if lessonData, isObject := lesson.(map[string]interface{}); isObject {
+			audio, _ := lessonData["Audio"].([]interface{})
+			for _, media := range audio {
+				migratePdfs(media)
+			}
+		}
+	}
+
+	return map[string]interface{}{
+		"FormatVersion": 2,
+		"Site":          data,
+	}, nil
+}
+
+// migratePdfs changes the PDF URLs of the item to documents.
+func migratePdfs(item interface{}) {
+	data, isObject := item.(map[string]interface{})
+	if !isObject {
+		return
+	}
+
+	pdfs, _ := data["Pdf"].([]interface{})
+	for i, pdf := range pdfs {
+		if url, isURL := pdf.(string); isURL {
+			pdfs[i] = map[string]interface{}{"URL": url}
+		}
+	}
+}
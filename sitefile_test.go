@@ -0,0 +1,111 @@
+package insidescraper
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMigrateBareSite(t *testing.T) {
+	file, err := DecodeSiteFile([]byte(`{
+		"Sections": {"section": {"ID": "section", "Title": "Section", "Pdf": ["https://example.com/section.pdf"],
+			"Sections": null, "Lessons": ["lesson"], "AudioCount": 1}},
+		"Lessons": {"lesson": {"ID": "lesson", "Title": "Lesson", "Pdf": null,
+			"Audio": [{"Source": "https://example.com/1.mp3", "Pdf": ["https://example.com/1.pdf"]}]}},
+		"TopLevel": [{"ID": "section", "Image": ""}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if file.FormatVersion != SiteFormatVersion {
+		t.Errorf("Expected format version %d, got %d", SiteFormatVersion, file.FormatVersion)
+	}
+
+	section := file.Site.Sections["section"]
+	if len(section.Pdf) != 1 || section.Pdf[0].URL != "https://example.com/section.pdf" || section.AudioCount != 1 {
+		t.Errorf("Expected the section to be migrated, got %+v %+v", section, *section.SiteData)
+	}
+
+	audio := file.Site.Lessons["lesson"].Audio
+	if len(audio) != 1 || len(audio[0].Pdf) != 1 || audio[0].Pdf[0].URL != "https://example.com/1.pdf" {
+		t.Errorf("Expected the media to be migrated, got %+v", audio)
+	}
+}
+
+func TestDecodeSiteFileErrors(t *testing.T) {
+	tests := map[string]string{
+		"unknown field":      `{"FormatVersion": 2, "Site": {"Sections": {"a": {"ID": "a", "Colour": "red"}}}}`,
+		"newer version":      `{"FormatVersion": 100, "Site": {}}`,
+		"invalid version":    `{"FormatVersion": "two", "Site": {}}`,
+		"wrong type":         `{"FormatVersion": 2, "Site": {"Lessons": {"a": {"ID": "a", "Audio": "1.mp3"}}}}`,
+		"unknown bare field": `{"Sections": {}, "Pages": {}}`,
+	}
+
+	for name, jsonText := range tests {
+		if _, err := DecodeSiteFile([]byte(jsonText)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestSiteFileRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sitefile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	path := filepath.Join(dir, "site.json")
+
+	err = WriteSiteFile(path, SiteFile{
+		ScraperVersion: "v1.2.3",
+		SourceURL:      "https://example.com/",
+		StartTime:      &start,
+		EndTime:        &end,
+		Site: Site{
+			Sections: map[string]SiteSection{"a": {SiteData: &SiteData{Title: "A"}, ID: "a"}},
+			TopLevel: []TopItem{{ID: "a"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := ReadSiteFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if file.FormatVersion != SiteFormatVersion || file.ScraperVersion != "v1.2.3" || file.SourceURL != "https://example.com/" ||
+		!file.StartTime.Equal(start) || !file.EndTime.Equal(end) || file.Site.Sections["a"].Title != "A" {
+		t.Errorf("Expected the file to be read as it was written, got %+v", file)
+	}
+}
+
+func TestRegisterMigration(t *testing.T) {
+	original := migrations[1]
+	defer RegisterMigration(1, original)
+
+	RegisterMigration(1, func(data map[string]interface{}) (map[string]interface{}, error) {
+		delete(data, "Obsolete")
+		return migrateBareSite(data)
+	})
+
+	file, err := DecodeSiteFile([]byte(`{"Sections": {}, "Lessons": {}, "TopLevel": [], "Obsolete": true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.FormatVersion != SiteFormatVersion {
+		t.Errorf("Expected the file to be migrated, got %+v", file)
+	}
+
+	if _, err := DecodeSiteFile([]byte(`{"FormatVersion": 0}`)); err == nil || !strings.Contains(err.Error(), "no migration") {
+		t.Errorf("Expected a missing migration to be an error, got %v", err)
+	}
+}
@@ -0,0 +1,191 @@
+package insidescraper
+
+import (
+	"sort"
+	"strings"
+)
+
+// parentIndex records which sections reference each section and lesson. It
+// records references, not what exists, so that it also has the parents of
+// missing items.
+type parentIndex struct {
+	// sections has the sections which list each ID in their Sections, and
+	// lessons has the sections which list it in their Lessons.
+	sections map[string]map[string]bool
+	lessons  map[string]map[string]bool
+}
+
+// getIndex gets the parent index, building it if it hasn't been built yet.
+func (site *Site) getIndex() *parentIndex {
+	if site.parents == nil {
+		site.ReindexParents()
+	}
+	return site.parents
+}
+
+// ReindexParents builds the index of the sections which contain each item from
+// scratch. The index is built when it's first used, and the methods of Site
+// keep it current; this is only needed after Sections were changed directly.
+func (site *Site) ReindexParents() {
+	site.parents = &parentIndex{
+		sections: make(map[string]map[string]bool, len(site.Sections)),
+		lessons:  make(map[string]map[string]bool, len(site.Lessons)),
+	}
+
+	for id, section := range site.Sections {
+		site.parents.addSection(id, section)
+	}
+}
+
+// addSection records the references of the section.
+func (index *parentIndex) addSection(id string, section SiteSection) {
+	for _, childID := range section.Sections {
+		index.add(index.sections, childID, id)
+	}
+	for _, lessonID := range section.Lessons {
+		index.add(index.lessons, lessonID, id)
+	}
+}
+
+// removeSection removes the references of the section.
+func (index *parentIndex) removeSection(id string, section SiteSection) {
+	for _, childID := range section.Sections {
+		index.remove(index.sections, childID, id)
+	}
+	for _, lessonID := range section.Lessons {
+		index.remove(index.lessons, lessonID, id)
+	}
+}
+
+func (index *parentIndex) add(references map[string]map[string]bool, childID, parentID string) {
+	if references[childID] == nil {
+		references[childID] = make(map[string]bool, 1)
+	}
+	references[childID][parentID] = true
+}
+
+func (index *parentIndex) remove(references map[string]map[string]bool, childID, parentID string) {
+	delete(references[childID], parentID)
+	if len(references[childID]) == 0 {
+		delete(references, childID)
+	}
+}
+
+// setSection changes the section, and updates the index.
+func (site *Site) setSection(id string, section SiteSection) {
+	index := site.getIndex()
+	if old, exists := site.Sections[id]; exists {
+		index.removeSection(id, old)
+	}
+	index.addSection(id, section)
+	site.Sections[id] = section
+}
+
+// deleteSection deletes the section, and updates the index.
+func (site *Site) deleteSection(id string) {
+	if section, exists := site.Sections[id]; exists {
+		site.getIndex().removeSection(id, section)
+		delete(site.Sections, id)
+	}
+}
+
+// Parents gets the sorted IDs of the sections which contain the section or
+// lesson with the given ID. Sections which reference a missing item are
+// included.
+func (site *Site) Parents(id string) []string {
+	index := site.getIndex()
+	parents := make([]string, 0, len(index.sections[id])+len(index.lessons[id]))
+
+	for parentID := range index.sections[id] {
+		parents = append(parents, parentID)
+	}
+	for parentID := range index.lessons[id] {
+		if !index.sections[id][parentID] {
+			parents = append(parents, parentID)
+		}
+	}
+
+	sort.Strings(parents)
+	return parents
+}
+
+// isSectionParent checks if the section with the given parent ID contains the
+// section with the given ID.
+func (site *Site) isSectionParent(id, parentID string) bool {
+	return site.getIndex().sections[id][parentID]
+}
+
+// sectionParents gets the sorted IDs of the sections which contain the section
+// with the given ID.
+func (site *Site) sectionParents(id string) []string {
+	return sortedKeys(site.getIndex().sections[id])
+}
+
+// lessonParents gets the sorted IDs of the sections which contain the lesson
+// with the given ID.
+func (site *Site) lessonParents(id string) []string {
+	return sortedKeys(site.getIndex().lessons[id])
+}
+
+// sortedKeys gets the keys of the set, sorted.
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// Paths gets every path from the top level to the section or lesson with the
+// given ID. Each path is the IDs of the sections, starting from a top level
+// section and ending with the item itself. Paths don't go around cycles.
+func (site *Site) Paths(id string) [][]string {
+	isTopLevel := make(map[string]bool, len(site.TopLevel))
+	for _, item := range site.TopLevel {
+		isTopLevel[item.ID] = true
+	}
+
+	paths := make([][]string, 0)
+	// The path from the item, up to the section which is being visited.
+	upward := []string{id}
+	onPath := map[string]bool{id: true}
+
+	var visit func(id string)
+	visit = func(id string) {
+		if isTopLevel[id] {
+			path := make([]string, len(upward))
+			for i, pathID := range upward {
+				path[len(upward)-1-i] = pathID
+			}
+			paths = append(paths, path)
+		}
+
+		// Above the item itself, only references to sections lead to it.
+		parents := site.sectionParents(id)
+		if len(upward) == 1 {
+			parents = site.Parents(id)
+		}
+
+		for _, parentID := range parents {
+			if onPath[parentID] {
+				continue
+			}
+
+			onPath[parentID] = true
+			upward = append(upward, parentID)
+			visit(parentID)
+			upward = upward[:len(upward)-1]
+			onPath[parentID] = false
+		}
+	}
+
+	visit(id)
+
+	sort.Slice(paths, func(i, j int) bool {
+		return strings.Join(paths[i], "\n") < strings.Join(paths[j], "\n")
+	})
+
+	return paths
+}
@@ -0,0 +1,128 @@
+package insidescraper
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPaths(t *testing.T) {
+	site := getGraphSite()
+	site.Sections["d"] = SiteSection{SiteData: &SiteData{}, ID: "d", Lessons: []string{"lesson"}}
+	site.Sections["b"] = SiteSection{SiteData: &SiteData{}, ID: "b", Sections: []string{"c", "b"}, Lessons: []string{"lesson"}}
+	site.Lessons = map[string]Lesson{"lesson": {SiteData: &SiteData{}, ID: "lesson"}}
+
+	expected := [][]string{
+		{"top", "a", "c", "d", "lesson"},
+		{"top", "b", "c", "d", "lesson"},
+		{"top", "b", "lesson"},
+	}
+
+	if paths := site.Paths("lesson"); !reflect.DeepEqual(paths, expected) {
+		t.Errorf("Expected paths %v, got %v", expected, paths)
+	}
+
+	if parents := site.Parents("lesson"); !reflect.DeepEqual(parents, []string{"b", "d"}) {
+		t.Errorf("Expected parents b and d, got %v", parents)
+	}
+
+	// x is only in a section which can't be reached.
+	if paths := site.Paths("x"); len(paths) != 0 {
+		t.Errorf("Expected no paths to x, got %v", paths)
+	}
+}
+
+func TestIndexAfterChanges(t *testing.T) {
+	cleaner := PostScraper{
+		Site: Site{
+			TopLevel: []TopItem{{ID: "top"}},
+			Sections: map[string]SiteSection{
+				"top":   {SiteData: &SiteData{}, ID: "top", Sections: []string{"bad", "multi", "single"}},
+				"multi": {SiteData: &SiteData{}, ID: "multi", Lessons: []string{"1", "2"}},
+				// A section which is really just a lesson.
+				"single": {SiteData: &SiteData{}, ID: "single", Lessons: []string{"3"}},
+				"good":   {SiteData: &SiteData{}, ID: "good", Lessons: []string{"4"}},
+			},
+			Lessons: map[string]Lesson{
+				"1": {SiteData: &SiteData{}, ID: "1", Audio: []Media{{Source: "1.mp3"}}},
+				"2": {SiteData: &SiteData{}, ID: "2", Audio: []Media{{Source: "2.mp3"}}},
+				"3": {SiteData: &SiteData{}, ID: "3", Audio: []Media{{Source: "3.mp3"}}},
+				"4": {SiteData: &SiteData{}, ID: "4", Audio: []Media{{Source: "4.mp3"}}},
+			},
+		},
+	}
+	site := &cleaner.Site
+
+	if parents := site.Parents("bad"); !reflect.DeepEqual(parents, []string{"top"}) {
+		t.Errorf("Expected the missing section to be in top, got %v", parents)
+	}
+
+	cleaner.applyFix("bad", &Correction{Guesses: []string{"good"}, Is404: true, Source: SectionType})
+
+	if parents := site.Parents("bad"); len(parents) != 0 {
+		t.Errorf("Expected nothing to reference the missing section, got %v", parents)
+	}
+	if paths := site.Paths("4"); !reflect.DeepEqual(paths, [][]string{{"top", "good", "4"}}) {
+		t.Errorf("Expected the corrected section to be in top, got %v", paths)
+	}
+
+	for _, id := range []string{"multi", "single"} {
+		if err := site.ConvertToLesson(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, id := range []string{"1", "2", "3"} {
+		if parents := site.Parents(id); len(parents) != 0 {
+			t.Errorf("Expected lesson %s not to be in a section, got %v", id, parents)
+		}
+	}
+	if paths := site.Paths("multi"); !reflect.DeepEqual(paths, [][]string{{"top", "multi"}}) {
+		t.Errorf("Expected the converted lesson to be in top, got %v", paths)
+	}
+
+	// The index matches one built from scratch.
+	index := site.parents
+	site.ReindexParents()
+	if !reflect.DeepEqual(index, site.parents) {
+		t.Errorf("Expected the index to be current, got %+v, not %+v", *index, *site.parents)
+	}
+}
+
+func TestIndexAfterMergeAndBreakCycles(t *testing.T) {
+	site := getGraphSite()
+	if parents := site.Parents("e"); len(parents) != 0 {
+		t.Fatalf("Expected nothing to contain e, got %v", parents)
+	}
+
+	other := Site{
+		Sections: map[string]SiteSection{
+			"d": withLessons(SiteSection{SiteData: &SiteData{}, ID: "d", Sections: []string{"e"}}, "1"),
+			"e": {SiteData: &SiteData{}, ID: "e"},
+		},
+	}
+	if _, err := site.Merge(other, PreferNewer); err != nil {
+		t.Fatal(err)
+	}
+	if parents := site.Parents("e"); !reflect.DeepEqual(parents, []string{"d"}) {
+		t.Errorf("Expected d to contain e after merging, got %v", parents)
+	}
+
+	site.BreakCycles()
+	if parents := site.Parents("b"); !reflect.DeepEqual(parents, []string{"top"}) {
+		t.Errorf("Expected only top to contain b after breaking cycles, got %v", parents)
+	}
+
+	// The index matches one built from scratch.
+	index := site.parents
+	site.ReindexParents()
+	if !reflect.DeepEqual(index, site.parents) {
+		t.Errorf("Expected the index to be current, got %+v, not %+v", *index, *site.parents)
+	}
+
+	// A section which is changed directly is only noticed after reindexing.
+	site.Sections["e"] = withLessons(site.Sections["e"], "1")
+	site.ReindexParents()
+	if parents := site.Parents("1"); !reflect.DeepEqual(parents, []string{"d", "e"}) {
+		t.Errorf("Expected d and e to contain lesson 1, got %v", parents)
+	}
+}
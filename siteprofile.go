@@ -0,0 +1,154 @@
+package insidescraper
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SiteProfile describes the layout of a site: the CSS selectors which find its
+// content. When the layout changes, or to scrape a sister site, only the
+// profile needs to change. It can be loaded from a JSON or YAML file with
+// LoadSiteProfile; any field which isn't set uses the insidechassidus default.
+type SiteProfile struct {
+	// Name describes the profile.
+	Name string `yaml:"Name"`
+
+	// MainMenu matches the links to the top level sections.
+	MainMenu string `yaml:"MainMenu"`
+	// MainMenuImage matches the image of a top level section, inside its link
+	// or, if it's not there, next to it.
+	MainMenuImage string `yaml:"MainMenuImage"`
+	// Row matches table rows, each of which is a lesson or a section.
+	Row string `yaml:"Row"`
+	// The columns of a row.
+	TitleColumn       string `yaml:"TitleColumn"`
+	MediaColumn       string `yaml:"MediaColumn"`
+	DescriptionColumn string `yaml:"DescriptionColumn"`
+	// RowMedia matches the media in the media column. A row without media is a section.
+	RowMedia string `yaml:"RowMedia"`
+	// RowVideo matches the videos in the media column: embedded players, video
+	// elements and links to video files.
+	RowVideo string `yaml:"RowVideo"`
+	// AudioAttribute is the attribute of a link which holds the URL of its audio.
+	AudioAttribute string `yaml:"AudioAttribute"`
+
+	// Lesson matches the audio links of lessons which aren't in a table.
+	Lesson string `yaml:"Lesson"`
+	// LessonTitle and LessonDescription are found in the parent of such a link.
+	LessonTitle       string `yaml:"LessonTitle"`
+	LessonDescription string `yaml:"LessonDescription"`
+
+	// SectionPdf matches links to PDFs which belong to the current section.
+	SectionPdf string `yaml:"SectionPdf"`
+	// Mobile matches the mobile only copy of the page, which is ignored.
+	Mobile string `yaml:"Mobile"`
+	// HereLinkText is the text of links in a section's description which lead
+	// to where the section really is.
+	HereLinkText string `yaml:"HereLinkText"`
+	// Breadcrumb matches each step of a page's breadcrumb trail.
+	Breadcrumb string `yaml:"Breadcrumb"`
+	// MainContent matches the content of a page, without the menus etc around it.
+	MainContent string `yaml:"MainContent"`
+}
+
+// DefaultSiteProfile returns the profile of insidechassidus.
+func DefaultSiteProfile() SiteProfile {
+	return siteProfileDefaults("mp3")
+}
+
+// siteProfileDefaults returns the profile of insidechassidus, with the
+// selectors of audio links built from the given attribute.
+func siteProfileDefaults(audioAttribute string) SiteProfile {
+	return SiteProfile{
+		Name:              "insidechassidus",
+		MainMenu:          "body.home #main-menu-fst > li > a ",
+		MainMenuImage:     "img",
+		Row:               "tbody tr",
+		TitleColumn:       "td:nth-child(1)",
+		MediaColumn:       "td:nth-child(2)",
+		DescriptionColumn: "td:nth-child(3)",
+		RowMedia:          "[" + audioAttribute + `],a[href$=".pdf"]`,
+		RowVideo:          `iframe[src],video,a[href$=".mp4"],a[href$=".m4v"]`,
+		AudioAttribute:    audioAttribute,
+		Lesson:            "div > div > a[" + audioAttribute + "]",
+		LessonTitle:       "h1",
+		LessonDescription: "div",
+		SectionPdf:        "div > div > a[href]",
+		Mobile:            ".visible-xs",
+		HereLinkText:      "here",
+		Breadcrumb:        ".breadcrumb li",
+		MainContent:       "#main_container",
+	}
+}
+
+// LoadSiteProfile loads a profile from a JSON or YAML file. The format is
+// chosen by the file's extension. A field which the profile doesn't have is an
+// error, so that a misspelled field isn't silently left at its default.
+func LoadSiteProfile(path string) (SiteProfile, error) {
+	var profile SiteProfile
+
+	text, err := ioutil.ReadFile(path)
+	if err != nil {
+		return profile, err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(text))
+		decoder.KnownFields(true)
+		// An empty file is an empty profile.
+		if err := decoder.Decode(&profile); err != nil && err != io.EOF {
+			return profile, err
+		}
+	default:
+		if err := decodeStrict(text, &profile); err != nil {
+			return profile, err
+		}
+	}
+
+	return profile.withDefaults(), nil
+}
+
+// withDefaults fills in every unset field with its default. The default
+// selectors of audio links use the profile's audio attribute.
+func (profile SiteProfile) withDefaults() SiteProfile {
+	setDefault(&profile.AudioAttribute, DefaultSiteProfile().AudioAttribute)
+	defaults := siteProfileDefaults(profile.AudioAttribute)
+
+	setDefault(&profile.Name, defaults.Name)
+	setDefault(&profile.MainMenu, defaults.MainMenu)
+	setDefault(&profile.MainMenuImage, defaults.MainMenuImage)
+	setDefault(&profile.Row, defaults.Row)
+	setDefault(&profile.TitleColumn, defaults.TitleColumn)
+	setDefault(&profile.MediaColumn, defaults.MediaColumn)
+	setDefault(&profile.DescriptionColumn, defaults.DescriptionColumn)
+	setDefault(&profile.RowMedia, defaults.RowMedia)
+	setDefault(&profile.RowVideo, defaults.RowVideo)
+	setDefault(&profile.Lesson, defaults.Lesson)
+	setDefault(&profile.LessonTitle, defaults.LessonTitle)
+	setDefault(&profile.LessonDescription, defaults.LessonDescription)
+	setDefault(&profile.SectionPdf, defaults.SectionPdf)
+	setDefault(&profile.Mobile, defaults.Mobile)
+	setDefault(&profile.HereLinkText, defaults.HereLinkText)
+	setDefault(&profile.Breadcrumb, defaults.Breadcrumb)
+	setDefault(&profile.MainContent, defaults.MainContent)
+
+	return profile
+}
+
+// setDefault sets the field to the default, if it isn't set.
+func setDefault(field *string, value string) {
+	if *field == "" {
+		*field = value
+	}
+}
+
+// audioLink is the selector of links to audio.
+func (profile SiteProfile) audioLink() string {
+	return "a[" + profile.AudioAttribute + "]"
+}
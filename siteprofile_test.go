@@ -0,0 +1,137 @@
+package insidescraper
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestLoadSiteProfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "profiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"sister.yaml": "Name: sister\nMainMenu: nav.main > a\nAudioAttribute: data-audio\n",
+		"sister.json": `{"Name": "sister", "MainMenu": "nav.main > a", "AudioAttribute": "data-audio"}`,
+	}
+
+	for name, text := range files {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		profile, err := LoadSiteProfile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if profile.Name != "sister" || profile.MainMenu != "nav.main > a" || profile.AudioAttribute != "data-audio" {
+			t.Errorf("%s: expected the profile's fields to be loaded, got %+v", name, profile)
+		}
+		if profile.Row != DefaultSiteProfile().Row {
+			t.Errorf("%s: expected unset fields to use their defaults, got %+v", name, profile)
+		}
+	}
+}
+
+func TestLessonScraperProfile(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<table><tbody>
+		<tr><td>Class One</td><td><a data-audio="https://example.com/1.mp3">Listen</a></td><td>A class</td></tr>
+	</tbody></table>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scraper := LessonScraper{
+		Row:     doc.Find("tr"),
+		Profile: SiteProfile{AudioAttribute: "data-audio"},
+	}
+	scraper.LoadLesson()
+
+	if len(scraper.Lesson.Audio) != 1 || scraper.Lesson.Audio[0].Source != "https://example.com/1.mp3" {
+		t.Errorf("Expected the audio to be found with the profile's attribute, got %+v", scraper.Lesson.Audio)
+	}
+}
+
+// audioAttributeTransport serves pages whose audio links use another attribute.
+type audioAttributeTransport struct {
+	Attribute string
+}
+
+func (transport audioAttributeTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	response, err := http.DefaultTransport.RoundTrip(request)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	body = bytes.Replace(body, []byte(` mp3="`), []byte(" "+transport.Attribute+`="`), -1)
+	response.Body = ioutil.NopCloser(bytes.NewReader(body))
+	response.ContentLength = int64(len(body))
+	response.Header.Del("Content-Length")
+	return response, nil
+}
+
+func TestScrapeWithAudioAttribute(t *testing.T) {
+	server := newFakeSite()
+	defer server.Close()
+
+	expected := InsideScraper{Options: fakeSiteOptions(server)}
+	if _, err := expected.Scrape(); err != nil {
+		t.Fatal(err)
+	}
+
+	options := fakeSiteOptions(server)
+	options.Transport = audioAttributeTransport{Attribute: "data-audio"}
+	options.Profile = SiteProfile{AudioAttribute: "data-audio"}
+	scraper := InsideScraper{Options: options}
+	if _, err := scraper.Scrape(); err != nil {
+		t.Fatal(err)
+	}
+
+	expectedJSON, _ := json.Marshal(expected.Site)
+	actualJSON, _ := json.Marshal(scraper.Site)
+	if string(actualJSON) != string(expectedJSON) {
+		t.Errorf("Scrape with the data-audio attribute:\n%s\ndoesn't match the default scrape:\n%s", actualJSON, expectedJSON)
+	}
+}
+
+func TestLoadSiteProfileUnknownField(t *testing.T) {
+	dir, err := ioutil.TempDir("", "profiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"typo.yaml": "Name: typo\nMainMenuu: nav.main > a\n",
+		"typo.json": `{"Name": "typo", "MainMenuu": "nav.main > a"}`,
+	}
+
+	for name, text := range files {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := LoadSiteProfile(path); err == nil {
+			t.Errorf("%s: expected the unknown field to be an error", name)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package insidescraper
 import (
 	"encoding/json"
 	"errors"
+	"time"
 )
 
 // Site contains all site data.
@@ -11,6 +12,9 @@ type Site struct {
 	Lessons  map[string]Lesson
 	// IDs of all top level sections.
 	TopLevel []TopItem
+
+	// parents is built when it's first needed. See Parents.
+	parents *parentIndex
 }
 
 // SiteSection describes a section of a site.
@@ -25,38 +29,106 @@ type SiteSection struct {
 	// AudioCount contains the total number of audio classes contained in this section,
 	// including  all descendant sections.
 	AudioCount int
+	// AudioDuration is the total length in seconds of those classes, if the
+	// media was enriched.
+	AudioDuration float64 `json:",omitempty"`
+	// VideoCount is the total number of videos in this section, including all
+	// descendant sections.
+	VideoCount int `json:",omitempty"`
+	// Page describes the page the section was scraped from, if it has one.
+	Page *SectionPage `json:",omitempty"`
 }
 
 // TopItem is a top level item on the site.
 type TopItem struct {
-	ID    string
+	ID string
+	// Image is the URL of the section's artwork.
 	Image string
+	// ImageFile is the name of the downloaded image, if images were downloaded.
+	// It's named by the hash of its content.
+	ImageFile string `json:",omitempty"`
 }
 
 // Lesson describes one lesson. It may contain multiple classes.
 type Lesson struct {
 	*SiteData
 	// ID is the URL of the lessons, if they are from their own page.
+	// Otherwise, it's made by MakeLessonID.
 	ID    string
 	Audio []Media
+	// Video holds video files and embedded videos.
+	Video []Media `json:",omitempty"`
+	// Ordinal is the lesson's position, if its title has one.
+	Ordinal *Ordinal `json:",omitempty"`
 }
 
+// MediaKind is the kind of a piece of media.
+type MediaKind string
+
+const (
+	// AudioMedia is an audio file. It's the zero value, so that audio from
+	// before there were other kinds doesn't need a kind.
+	AudioMedia MediaKind = ""
+	// VideoFile is a video file, such as an MP4.
+	VideoFile MediaKind = "video"
+	// YouTubeVideo is a YouTube video. Its source is the URL of the embedded player.
+	YouTubeVideo MediaKind = "youtube"
+	// VimeoVideo is a Vimeo video. Its source is the URL of the embedded player.
+	VimeoVideo MediaKind = "vimeo"
+)
+
 // Media contains information about a particular piece of media.
 type Media struct {
 	// Note that a media item will *only* have it's own PDF if it was converted from a lesson.
 	*SiteData
 	Source string
+	Kind   MediaKind `json:",omitempty"`
+	// Ordinal is the class's position, if its title has one.
+	Ordinal *Ordinal `json:",omitempty"`
+
+	// The rest is filled in by MediaEnricher, if it's used.
+
+	// Size is the size of the file, in bytes.
+	Size         int64      `json:",omitempty"`
+	ContentType  string     `json:",omitempty"`
+	LastModified *time.Time `json:",omitempty"`
+	// Duration is the length of the audio, in seconds.
+	Duration float64 `json:",omitempty"`
 }
 
 // SiteData is a base type used by other site structures.
 type SiteData struct {
 	Title       string
 	Description string
+	// DescriptionHTML is the description with its formatting and links, as
+	// sanitized HTML. See Site.LinkDescriptions for links within the site.
+	DescriptionHTML string `json:",omitempty"`
 	// PDFs can pop up at any level.
 	// For example, sometimes a section has a pdf for it. This usually (probably always) happens
 	// when the section contains only lessons, when it will anyway be converted to a lesson.
 	// See https://insidechassidus.org/thought-and-history/123-kabbala-and-philosophy-series/1699-chassidus-understanding-what-can-be-understood-of-g-dliness/section-one-before-logic
-	Pdf []string
+	Pdf []Document
+}
+
+// Document is a PDF, such as a source sheet or the text of a maamar.
+type Document struct {
+	URL string
+	// Title is the text of the link to the document, eg "Source sheet".
+	Title string `json:",omitempty"`
+	// Media is the source of the class the document is for, if it's for one.
+	Media string `json:",omitempty"`
+	// Page is the URL of the page the document was found on.
+	Page string `json:",omitempty"`
+}
+
+// MissingLessonError is returned when a section references a lesson which doesn't exist.
+type MissingLessonError struct {
+	LessonID  string
+	SectionID string
+}
+
+func (err *MissingLessonError) Error() string {
+	return "Lesson " + err.LessonID + " (referenced by " + err.SectionID + ") doesn't exist"
 }
 
 // ConvertToLesson converts the section to a lesson if it only contains single-audio lessons.
@@ -74,7 +146,7 @@ func (site *Site) ConvertToLesson(sectionID string) error {
 		return errors.New("Does not contain any lessons")
 	case 1:
 		// The section is really just a single lesson. Get rid of the pretend section.
-		delete(site.Sections, sectionID)
+		site.deleteSection(sectionID)
 
 		/*
 		 * Change the ID and key of the lesson to the old section ID.
@@ -89,21 +161,24 @@ func (site *Site) ConvertToLesson(sectionID string) error {
 		delete(site.Lessons, oldLessonID)
 		return nil
 	default:
-		// A section can only be converted to a lesson if all of it's lessons are single audio files.
+		// A section can only be converted to a lesson if all of it's lessons are single media files.
 		for _, lessonID := range section.Lessons {
 			if lesson, exists := site.Lessons[lessonID]; exists {
-				if len(lesson.Audio) > 1 {
+				if len(lesson.Audio)+len(lesson.Video) > 1 {
 					return errors.New("Contains complex lessons: " + lesson.Title + "," + sectionID)
 				}
 			} else {
-				panic("Hey, why doesn't " + lessonID + " (referenced by " + section.ID + ")" + " exist?")
+				return &MissingLessonError{
+					LessonID:  lessonID,
+					SectionID: sectionID,
+				}
 			}
 		}
 	}
 
 	// Move section over to lesson.
 	site.Lessons[sectionID] = site.getLessonFromSection(sectionID)
-	delete(site.Sections, sectionID)
+	site.deleteSection(sectionID)
 
 	return nil
 }
@@ -125,11 +200,16 @@ func (site *Site) getLessonFromSection(sectionID string) Lesson {
 		lessonToConvert := site.Lessons[lessonID]
 
 		if len(lessonToConvert.Audio) != 0 {
-			newLesson.Audio = append(newLesson.Audio, Media{
-				// Note that SiteData also includes PDF URL.
-				SiteData: lessonToConvert.SiteData,
-				Source:   lessonToConvert.Audio[0].Source,
-			})
+			media := lessonToConvert.Audio[0]
+			// Note that SiteData also includes PDF URL.
+			media.SiteData = lessonToConvert.SiteData
+			newLesson.Audio = append(newLesson.Audio, media)
+		}
+
+		if len(lessonToConvert.Video) != 0 {
+			media := lessonToConvert.Video[0]
+			media.SiteData = lessonToConvert.SiteData
+			newLesson.Video = append(newLesson.Video, media)
 		}
 
 		// Delete the old, single media lesson.
@@ -139,44 +219,57 @@ func (site *Site) getLessonFromSection(sectionID string) Lesson {
 	return newLesson
 }
 
+// UnmarshalJSON decodes the media, making sure it has SiteData. Like the
+// other types of the site, fields which it doesn't have are an error.
 func (i *Media) UnmarshalJSON(data []byte) error {
-	// See https://stackoverflow.com/a/43178272
-	type forceDefaultUnmarshalType *Media
-
-	json.Unmarshal(data, forceDefaultUnmarshalType(i))
+	// A type without the UnmarshalJSON method, so that the default decoding is used.
+	type plainMedia Media
+	plain := plainMedia{SiteData: &SiteData{}}
 
-	// See https://stackoverflow.com/a/54624012
-	siteData := &SiteData{}
-	json.Unmarshal(data, siteData)
-	i.SiteData = siteData
+	err := decodeStrict(data, &plain)
+	*i = Media(plain)
 
-	return nil
+	return err
 }
 
+// UnmarshalJSON decodes the section, making sure it has SiteData.
 func (i *SiteSection) UnmarshalJSON(data []byte) error {
-	// See https://stackoverflow.com/a/43178272
-	type forceDefaultUnmarshalType *SiteSection
+	// A type without the UnmarshalJSON method, so that the default decoding is used.
+	type plainSection SiteSection
+	plain := plainSection{SiteData: &SiteData{}}
 
-	json.Unmarshal(data, forceDefaultUnmarshalType(i))
+	err := decodeStrict(data, &plain)
+	*i = SiteSection(plain)
 
-	// See https://stackoverflow.com/a/54624012
-	siteData := &SiteData{}
-	json.Unmarshal(data, siteData)
-	i.SiteData = siteData
-
-	return nil
+	return err
 }
 
+// UnmarshalJSON decodes the lesson, making sure it has SiteData.
 func (i *Lesson) UnmarshalJSON(data []byte) error {
-	// See https://stackoverflow.com/a/43178272
-	type forceDefaultUnmarshalType *Lesson
+	// A type without the UnmarshalJSON method, so that the default decoding is used.
+	type plainLesson Lesson
+	plain := plainLesson{SiteData: &SiteData{}}
+
+	err := decodeStrict(data, &plain)
+	*i = Lesson(plain)
 
-	json.Unmarshal(data, forceDefaultUnmarshalType(i))
+	return err
+}
+
+// UnmarshalJSON decodes the document. Old site data has just the URL, as a string.
+func (i *Document) UnmarshalJSON(data []byte) error {
+	var url string
+	if err := json.Unmarshal(data, &url); err == nil {
+		*i = Document{URL: url}
+		return nil
+	}
 
-	// See https://stackoverflow.com/a/54624012
-	siteData := &SiteData{}
-	json.Unmarshal(data, siteData)
-	i.SiteData = siteData
+	// A type without the UnmarshalJSON method, so that the default decoding is used.
+	type plainDocument Document
+	var plain plainDocument
 
-	return nil
+	err := decodeStrict(data, &plain)
+	*i = Document(plain)
+
+	return err
 }
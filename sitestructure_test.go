@@ -0,0 +1,56 @@
+package insidescraper
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestConvertToLessonMissingLesson(t *testing.T) {
+	site := Site{
+		Sections: map[string]SiteSection{
+			"section": SiteSection{
+				SiteData: &SiteData{},
+				ID:       "section",
+				Lessons:  []string{"exists", "missing"},
+			},
+		},
+		Lessons: map[string]Lesson{
+			"exists": Lesson{SiteData: &SiteData{}, ID: "exists"},
+		},
+	}
+
+	err := site.ConvertToLesson("section")
+
+	missing, isMissing := err.(*MissingLessonError)
+	if !isMissing {
+		t.Fatalf("Expected a MissingLessonError, got %v", err)
+	}
+	if missing.LessonID != "missing" || missing.SectionID != "section" {
+		t.Errorf("Expected the missing lesson to be reported, got %+v", missing)
+	}
+}
+
+func TestDecodeOldPdfs(t *testing.T) {
+	var lesson Lesson
+	err := json.Unmarshal([]byte(`{"ID": "lesson", "Title": "Lesson", "Pdf": [
+		"https://example.com/old.pdf",
+		{"URL": "https://example.com/new.pdf", "Title": "Source sheet", "Media": "https://example.com/1.mp3"}
+	]}`), &lesson)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Document{
+		{URL: "https://example.com/old.pdf"},
+		{URL: "https://example.com/new.pdf", Title: "Source sheet", Media: "https://example.com/1.mp3"},
+	}
+
+	if len(lesson.Pdf) != len(expected) {
+		t.Fatalf("Expected %d PDFs, got %d", len(expected), len(lesson.Pdf))
+	}
+	for i, document := range lesson.Pdf {
+		if document != expected[i] {
+			t.Errorf("Expected %+v, got %+v", expected[i], document)
+		}
+	}
+}
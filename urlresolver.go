@@ -0,0 +1,102 @@
+package insidescraper
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// URLResolver finds the URL which a link finally leads to, after all redirects.
+type URLResolver interface {
+	Resolve(url string) (string, error)
+}
+
+// RedirectCache is a URLResolver which remembers where every URL leads, so that
+// each URL is only requested once. If it has a path, it can be saved to disk and
+// loaded again on the next run.
+// URLs which can't be resolved aren't cached; they're recorded in Failures for
+// this run only, and tried again on the next one.
+type RedirectCache struct {
+	// Redirects maps each URL to where it finally leads.
+	Redirects map[string]string
+	// Failures maps each URL which couldn't be resolved to the error. It isn't saved.
+	Failures map[string]string `json:"-"`
+
+	path      string
+	transport http.RoundTripper
+	lock      sync.Mutex
+}
+
+// NewRedirectCache creates a cache which makes requests with the given transport,
+// which may be nil. If path isn't empty, the cache is loaded from that file, if it exists.
+func NewRedirectCache(path string, transport http.RoundTripper) (*RedirectCache, error) {
+	cache := &RedirectCache{
+		Redirects: make(map[string]string, 1000),
+		Failures:  make(map[string]string),
+		path:      path,
+		transport: transport,
+	}
+
+	if path == "" {
+		return cache, nil
+	}
+
+	jsonText, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cache, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(jsonText, cache); err != nil {
+		return nil, err
+	}
+
+	if cache.Redirects == nil {
+		cache.Redirects = make(map[string]string, 1000)
+	}
+
+	return cache, nil
+}
+
+// Resolve gets the URL after all redirects, from the cache if it's there.
+func (cache *RedirectCache) Resolve(url string) (string, error) {
+	cache.lock.Lock()
+	finalURL, exists := cache.Redirects[url]
+	cache.lock.Unlock()
+
+	if exists {
+		return finalURL, nil
+	}
+
+	response, err := clientFor(cache.transport).Head(url)
+
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	if err != nil {
+		cache.Failures[url] = err.Error()
+		return "", err
+	}
+	response.Body.Close()
+
+	finalURL = response.Request.URL.String()
+	cache.Redirects[url] = finalURL
+	delete(cache.Failures, url)
+
+	return finalURL, nil
+}
+
+// Save writes the cache to its file. It does nothing if the cache doesn't have a path.
+func (cache *RedirectCache) Save() error {
+	if cache.path == "" {
+		return nil
+	}
+
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	return WriteJSON(cache.path, cache)
+}
@@ -0,0 +1,123 @@
+package insidescraper
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRedirectCache(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+		}
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "redirects")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cachePath := filepath.Join(dir, "redirects.json")
+
+	cache, err := NewRedirectCache(cachePath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		finalURL, err := cache.Resolve(server.URL + "/old")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if finalURL != server.URL+"/new" {
+			t.Errorf("Expected %s/new, got %s", server.URL, finalURL)
+		}
+	}
+
+	// One request for the old URL, and one for where it redirects to.
+	if requests != 2 {
+		t.Errorf("Expected 2 requests, got %d", requests)
+	}
+
+	if err := cache.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := NewRedirectCache(cachePath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if finalURL, _ := loaded.Resolve(server.URL + "/old"); finalURL != server.URL+"/new" || requests != 2 {
+		t.Errorf("Expected the saved redirect to be used, got %s after %d requests", finalURL, requests)
+	}
+}
+
+func TestRedirectCacheFailure(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	deadURL := server.URL + "/gone"
+	server.Close()
+
+	cache, _ := NewRedirectCache("", nil)
+
+	if _, err := cache.Resolve(deadURL); err == nil {
+		t.Fatal("Expected an error")
+	}
+
+	if _, exists := cache.Failures[deadURL]; !exists {
+		t.Error("Expected the failure to be recorded")
+	}
+	if _, exists := cache.Redirects[deadURL]; exists {
+		t.Error("Expected the failure not to be cached as a redirect")
+	}
+
+	dir, err := ioutil.TempDir("", "redirects")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cache.path = filepath.Join(dir, "redirects.json")
+	if err := cache.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if saved, _ := ioutil.ReadFile(cache.path); strings.Contains(string(saved), "Failures") {
+		t.Errorf("Expected the failures not to be saved, got %s", saved)
+	}
+}
+
+// fakeResolver resolves URLs from a map, and leaves any other URL as it is.
+type fakeResolver map[string]string
+
+func (resolver fakeResolver) Resolve(url string) (string, error) {
+	if finalURL, exists := resolver[url]; exists {
+		return finalURL, nil
+	}
+	return url, nil
+}
+
+func TestScrapeWithURLResolver(t *testing.T) {
+	server := newFakeSite()
+	defer server.Close()
+
+	options := fakeSiteOptions(server)
+	options.URLResolver = fakeResolver{
+		server.URL + "/b": server.URL + "/section-b",
+	}
+
+	scraper := InsideScraper{Options: options}
+	if _, err := scraper.Scrape(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, exists := scraper.Site.Sections[server.URL+"/section-b"]; !exists {
+		t.Error("Expected the resolver to be used for the section's ID")
+	}
+}
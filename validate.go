@@ -0,0 +1,280 @@
+package insidescraper
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Severity is how bad a finding is.
+type Severity string
+
+const (
+	// SeverityError is data which is broken, like a reference to a lesson which
+	// doesn't exist.
+	SeverityError Severity = "error"
+	// SeverityWarning is data which is probably wrong, like an empty section.
+	SeverityWarning Severity = "warning"
+)
+
+// FindingKind is the kind of problem which Site.Validate found.
+type FindingKind string
+
+const (
+	// MissingTopLevel is a top level section which doesn't exist.
+	MissingTopLevel FindingKind = "missing-top-level"
+	// MissingSection is a section which contains a section which doesn't exist.
+	MissingSection FindingKind = "missing-section"
+	// MissingLesson is a section which contains a lesson which doesn't exist.
+	MissingLesson FindingKind = "missing-lesson"
+	// MissingSource is media without a source.
+	MissingSource FindingKind = "missing-source"
+	// EmptySection is a section without any sections or lessons.
+	EmptySection FindingKind = "empty-section"
+	// EmptyLesson is a lesson without any audio, video or PDFs.
+	EmptyLesson FindingKind = "empty-lesson"
+	// Unreachable is a section which can't be reached from the top level sections.
+	Unreachable FindingKind = "unreachable"
+	// MisplacedSection is a section whose breadcrumbs say it's somewhere else.
+	MisplacedSection FindingKind = "misplaced-section"
+)
+
+// severities is the severity of each kind of finding.
+var severities = map[FindingKind]Severity{
+	MissingTopLevel:  SeverityError,
+	MissingSection:   SeverityError,
+	MissingLesson:    SeverityError,
+	MissingSource:    SeverityError,
+	EmptySection:     SeverityWarning,
+	EmptyLesson:      SeverityWarning,
+	Unreachable:      SeverityWarning,
+	MisplacedSection: SeverityWarning,
+}
+
+// Finding is a problem with the site data.
+type Finding struct {
+	Kind     FindingKind
+	Severity Severity
+	// ID is the section or lesson the finding is about. For a missing item, it's
+	// the ID which is missing.
+	ID string
+	// Path is the IDs of the sections from the top level down to the section
+	// which contains the item, if it can be reached.
+	Path    []string
+	Message string
+}
+
+// Validate checks the integrity of the site: that everything which is
+// referenced exists, and that sections and lessons aren't empty.
+func (site *Site) Validate() []Finding {
+	findings := make([]Finding, 0)
+	paths := site.getShortestPaths()
+
+	add := func(kind FindingKind, id string, path []string, message string) {
+		findings = append(findings, Finding{
+			Kind:     kind,
+			Severity: severities[kind],
+			ID:       id,
+			Path:     path,
+			Message:  message,
+		})
+	}
+
+	for _, item := range site.TopLevel {
+		if _, exists := site.Sections[item.ID]; !exists {
+			add(MissingTopLevel, item.ID, nil, "Top level section doesn't exist")
+		}
+	}
+
+	for sectionID, section := range site.Sections {
+		path, isReachable := paths[sectionID]
+		if !isReachable {
+			add(Unreachable, sectionID, nil, "Section can't be reached from the top level")
+		}
+		childPath := append(append([]string{}, path...), sectionID)
+
+		if len(section.Sections) == 0 && len(section.Lessons) == 0 {
+			add(EmptySection, sectionID, path, "Section contains no content")
+		}
+
+		for _, childID := range section.Sections {
+			if _, exists := site.Sections[childID]; !exists {
+				add(MissingSection, childID, childPath, "Contains missing section")
+			}
+		}
+
+		for _, lessonID := range section.Lessons {
+			if _, exists := site.Lessons[lessonID]; !exists {
+				add(MissingLesson, lessonID, childPath, "Contains missing lesson")
+			}
+		}
+	}
+
+	// The path of a lesson is the path of the first section which contains it.
+	lessonPaths := make(map[string][]string, len(site.Lessons))
+	for _, sectionID := range site.sortedSectionIDs() {
+		path, isReachable := paths[sectionID]
+		if !isReachable {
+			continue
+		}
+		for _, lessonID := range site.Sections[sectionID].Lessons {
+			if _, exists := lessonPaths[lessonID]; !exists {
+				lessonPaths[lessonID] = append(append([]string{}, path...), sectionID)
+			}
+		}
+	}
+
+	for lessonID, lesson := range site.Lessons {
+		if len(lesson.Audio) == 0 && len(lesson.Video) == 0 && (lesson.SiteData == nil || len(lesson.Pdf) == 0) {
+			add(EmptyLesson, lessonID, lessonPaths[lessonID], "Lesson contains no audio, video or PDF")
+		}
+
+		for name, media := range map[string][]Media{"audio": lesson.Audio, "video": lesson.Video} {
+			for i := range media {
+				if media[i].Source == "" {
+					add(MissingSource, lessonID, lessonPaths[lessonID], fmt.Sprintf("%s[%d] has no source", name, i))
+				}
+			}
+		}
+	}
+
+	for _, mismatch := range site.CheckBreadcrumbs(nil) {
+		add(MisplacedSection, mismatch.SectionID, paths[mismatch.SectionID],
+			"Breadcrumbs say the section is in "+mismatch.BreadcrumbParent)
+	}
+
+	sort.Slice(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.Severity != b.Severity {
+			return a.Severity == SeverityError
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.ID != b.ID {
+			return a.ID < b.ID
+		}
+		if path, otherPath := strings.Join(a.Path, "\n"), strings.Join(b.Path, "\n"); path != otherPath {
+			return path < otherPath
+		}
+		return a.Message < b.Message
+	})
+
+	return findings
+}
+
+// getShortestPaths finds the shortest path from the top level to every section
+// which can be reached. The path is the IDs of the sections above it.
+func (site *Site) getShortestPaths() map[string][]string {
+	paths := make(map[string][]string, len(site.Sections))
+	queue := make([]string, 0, len(site.Sections))
+
+	for _, item := range site.TopLevel {
+		if _, exists := site.Sections[item.ID]; !exists {
+			continue
+		}
+		if _, exists := paths[item.ID]; !exists {
+			paths[item.ID] = []string{}
+			queue = append(queue, item.ID)
+		}
+	}
+
+	for len(queue) > 0 {
+		sectionID := queue[0]
+		queue = queue[1:]
+		childPath := append(append([]string{}, paths[sectionID]...), sectionID)
+
+		for _, childID := range site.Sections[sectionID].Sections {
+			if _, exists := site.Sections[childID]; !exists {
+				continue
+			}
+			if _, exists := paths[childID]; !exists {
+				paths[childID] = childPath
+				queue = append(queue, childID)
+			}
+		}
+	}
+
+	return paths
+}
+
+// sortedSectionIDs gets the IDs of all the sections, in order.
+func (site *Site) sortedSectionIDs() []string {
+	ids := make([]string, 0, len(site.Sections))
+	for id := range site.Sections {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// FindingLimits are the most findings which are acceptable, for example to
+// release the data. Negative limits aren't checked.
+type FindingLimits struct {
+	Errors   int
+	Warnings int
+	// Kinds limits the findings of particular kinds.
+	Kinds map[FindingKind]int
+}
+
+// Exceeded describes every limit which the findings are over. It's empty if
+// the findings are acceptable.
+func (limits FindingLimits) Exceeded(findings []Finding) []string {
+	severityCounts := make(map[Severity]int, 2)
+	kindCounts := make(map[FindingKind]int, len(severities))
+	for _, finding := range findings {
+		severityCounts[finding.Severity]++
+		kindCounts[finding.Kind]++
+	}
+
+	exceeded := make([]string, 0)
+	check := func(name string, count, limit int) {
+		if limit >= 0 && count > limit {
+			exceeded = append(exceeded, fmt.Sprintf("%d %s (the limit is %d)", count, name, limit))
+		}
+	}
+
+	check("errors", severityCounts[SeverityError], limits.Errors)
+	check("warnings", severityCounts[SeverityWarning], limits.Warnings)
+
+	kinds := make([]string, 0, len(limits.Kinds))
+	for kind := range limits.Kinds {
+		kinds = append(kinds, string(kind))
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		check(kind, kindCounts[FindingKind(kind)], limits.Kinds[FindingKind(kind)])
+	}
+
+	return exceeded
+}
+
+// ParseKindLimits parses comma separated limits of particular kinds of
+// findings, like "empty-section=10,unreachable=0". A kind which Validate doesn't
+// find is an error, so that a misspelled limit isn't silently ignored.
+func ParseKindLimits(text string) (map[FindingKind]int, error) {
+	limits := make(map[FindingKind]int)
+
+	for _, limit := range strings.Split(text, ",") {
+		if limit == "" {
+			continue
+		}
+
+		parts := strings.SplitN(limit, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("limit %q should be kind=count", limit)
+		}
+		kind := FindingKind(parts[0])
+		if _, exists := severities[kind]; !exists {
+			return nil, fmt.Errorf("limit %q is of an unknown kind of finding", limit)
+		}
+		count, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("limit %q should be kind=count: %v", limit, err)
+		}
+		limits[kind] = count
+	}
+
+	return limits, nil
+}
@@ -0,0 +1,68 @@
+package insidescraper
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	site := Site{
+		TopLevel: []TopItem{{ID: "top"}, {ID: "gone"}},
+		Sections: map[string]SiteSection{
+			"top": {SiteData: &SiteData{}, ID: "top", Sections: []string{"a", "missing-section"}},
+			"a":   {SiteData: &SiteData{}, ID: "a", Sections: []string{"empty"}, Lessons: []string{"good", "empty-lesson", "missing-lesson"}},
+			"empty": {SiteData: &SiteData{}, ID: "empty", Page: &SectionPage{
+				URL:         "empty",
+				Breadcrumbs: []Breadcrumb{{Title: "Top", URL: "top"}, {Title: "Empty"}},
+			}},
+			"orphan": {SiteData: &SiteData{}, ID: "orphan", Lessons: []string{"video"}},
+		},
+		Lessons: map[string]Lesson{
+			"good":         {SiteData: &SiteData{}, ID: "good", Audio: []Media{{Source: "1.mp3"}}},
+			"empty-lesson": {SiteData: &SiteData{}, ID: "empty-lesson", Audio: []Media{{}}},
+			"video":        {ID: "video", Video: []Media{{Source: "1.mp4", Kind: VideoFile}, {Kind: VideoFile}}},
+		},
+	}
+
+	expected := []Finding{
+		{Kind: MissingLesson, Severity: SeverityError, ID: "missing-lesson", Path: []string{"top", "a"}, Message: "Contains missing lesson"},
+		{Kind: MissingSection, Severity: SeverityError, ID: "missing-section", Path: []string{"top"}, Message: "Contains missing section"},
+		{Kind: MissingSource, Severity: SeverityError, ID: "empty-lesson", Path: []string{"top", "a"}, Message: "audio[0] has no source"},
+		{Kind: MissingSource, Severity: SeverityError, ID: "video", Message: "video[1] has no source"},
+		{Kind: MissingTopLevel, Severity: SeverityError, ID: "gone", Message: "Top level section doesn't exist"},
+		{Kind: EmptySection, Severity: SeverityWarning, ID: "empty", Path: []string{"top", "a"}, Message: "Section contains no content"},
+		{Kind: MisplacedSection, Severity: SeverityWarning, ID: "empty", Path: []string{"top", "a"}, Message: "Breadcrumbs say the section is in top"},
+		{Kind: Unreachable, Severity: SeverityWarning, ID: "orphan", Message: "Section can't be reached from the top level"},
+	}
+
+	findings := site.Validate()
+	if !reflect.DeepEqual(findings, expected) {
+		t.Errorf("Expected findings\n%+v\ngot\n%+v", expected, findings)
+	}
+
+	limits := FindingLimits{Errors: 5, Warnings: -1, Kinds: map[FindingKind]int{EmptySection: 0}}
+	if exceeded := limits.Exceeded(findings); !reflect.DeepEqual(exceeded, []string{"1 empty-section (the limit is 0)"}) {
+		t.Errorf("Unexpected exceeded limits %v", exceeded)
+	}
+
+	limits.Errors = 4
+	if exceeded := limits.Exceeded(findings); len(exceeded) != 2 {
+		t.Errorf("Expected errors to be over the limit, got %v", exceeded)
+	}
+}
+
+func TestParseKindLimits(t *testing.T) {
+	limits, err := ParseKindLimits("empty-section=10,unreachable=0,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := map[FindingKind]int{EmptySection: 10, Unreachable: 0}; !reflect.DeepEqual(limits, expected) {
+		t.Errorf("Expected limits %v, got %v", expected, limits)
+	}
+
+	for _, text := range []string{"empty-sections=10", "empty-section", "empty-section=many"} {
+		if _, err := ParseKindLimits(text); err == nil {
+			t.Errorf("Expected %q to be an error", text)
+		}
+	}
+}
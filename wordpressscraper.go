@@ -0,0 +1,356 @@
+package insidescraper
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// WordPressScraper builds the site from the WordPress REST API, instead of from
+// the HTML pages. Categories are sections, and posts are lessons. The audio and
+// PDFs of a post are its attachments, and any which are embedded in its content.
+type WordPressScraper struct {
+	// BaseURL is the root of the WordPress site, eg https://insidechassidus.org/
+	BaseURL string
+	// Transport makes the requests. If nil, http.DefaultTransport is used.
+	Transport http.RoundTripper
+	// PerPage is how many items are requested at a time. It defaults to 100,
+	// which is the most WordPress allows.
+	PerPage int
+	// SortAudio sorts the audio of each lesson by the ordinals in their titles.
+	SortAudio bool
+	Site      Site
+}
+
+// wpRendered is a field which WordPress returns as HTML.
+type wpRendered struct {
+	Rendered string `json:"rendered"`
+}
+
+type wpCategory struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Link        string `json:"link"`
+	Parent      int    `json:"parent"`
+}
+
+type wpPost struct {
+	ID         int        `json:"id"`
+	Link       string     `json:"link"`
+	Title      wpRendered `json:"title"`
+	Excerpt    wpRendered `json:"excerpt"`
+	Content    wpRendered `json:"content"`
+	Categories []int      `json:"categories"`
+	Embedded   struct {
+		FeaturedMedia []wpMedia `json:"wp:featuredmedia"`
+	} `json:"_embedded"`
+}
+
+type wpMedia struct {
+	ID        int        `json:"id"`
+	SourceURL string     `json:"source_url"`
+	MimeType  string     `json:"mime_type"`
+	Title     wpRendered `json:"title"`
+	Caption   wpRendered `json:"caption"`
+	// Post is the ID of the post the media is attached to.
+	Post int `json:"post"`
+}
+
+// Scrape loads the whole site from the API.
+func (scraper *WordPressScraper) Scrape() error {
+	scraper.Site = Site{
+		Sections: make(map[string]SiteSection, 1000),
+		Lessons:  make(map[string]Lesson, 1000),
+		TopLevel: make([]TopItem, 0, 10),
+	}
+
+	var categories []wpCategory
+	if err := scraper.getAll("categories", nil, &categories); err != nil {
+		return err
+	}
+
+	var media []wpMedia
+	if err := scraper.getAll("media", nil, &media); err != nil {
+		return err
+	}
+
+	var posts []wpPost
+	if err := scraper.getAll("posts", url.Values{"_embed": {""}}, &posts); err != nil {
+		return err
+	}
+
+	sectionIDs := scraper.loadCategories(categories)
+
+	// Group the attachments by the post they're attached to.
+	attachments := make(map[int][]wpMedia, len(posts))
+	for _, item := range media {
+		if item.Post != 0 {
+			attachments[item.Post] = append(attachments[item.Post], item)
+		}
+	}
+
+	for _, post := range posts {
+		lesson := getLessonFromPost(post, attachments[post.ID])
+		setOrdinals(&lesson)
+		if scraper.SortAudio {
+			lesson.SortAudio()
+		}
+		scraper.Site.Lessons[lesson.ID] = lesson
+
+		for _, categoryID := range post.Categories {
+			if sectionID, exists := sectionIDs[categoryID]; exists {
+				section := scraper.Site.Sections[sectionID]
+				section.Lessons = append(section.Lessons, lesson.ID)
+				scraper.Site.Sections[sectionID] = section
+			}
+		}
+	}
+
+	scraper.Site.LinkDescriptions(nil)
+
+	return nil
+}
+
+// loadCategories creates a section for every category, and puts them in their parents.
+// It returns the section ID of each category.
+func (scraper *WordPressScraper) loadCategories(categories []wpCategory) map[int]string {
+	sectionIDs := make(map[int]string, len(categories))
+
+	for _, category := range categories {
+		sectionIDs[category.ID] = category.Link
+		scraper.Site.Sections[category.Link] = SiteSection{
+			SiteData: &SiteData{
+				Title:       html.UnescapeString(category.Name),
+				Description: strings.TrimSpace(html.UnescapeString(category.Description)),
+			},
+			ID:       category.Link,
+			Sections: make([]string, 0, 10),
+			Lessons:  make([]string, 0, 20),
+		}
+	}
+
+	for _, category := range categories {
+		if category.Parent == 0 {
+			scraper.Site.TopLevel = append(scraper.Site.TopLevel, TopItem{
+				ID: category.Link,
+			})
+			continue
+		}
+
+		if parentID, exists := sectionIDs[category.Parent]; exists {
+			parent := scraper.Site.Sections[parentID]
+			parent.Sections = append(parent.Sections, category.Link)
+			scraper.Site.Sections[parentID] = parent
+		}
+	}
+
+	return sectionIDs
+}
+
+// getLessonFromPost makes a lesson from the post, with all of its media.
+func getLessonFromPost(post wpPost, attachments []wpMedia) Lesson {
+	lesson := Lesson{
+		SiteData: &SiteData{
+			Title:           html.UnescapeString(post.Title.Rendered),
+			Description:     getTextFromHTML(post.Excerpt.Rendered),
+			DescriptionHTML: getSanitizedHTML(post.Excerpt.Rendered, post.Link),
+		},
+		ID:    post.Link,
+		Audio: make([]Media, 0, len(attachments)),
+	}
+
+	// Don't add the same media twice, if it's both attached and embedded.
+	sources := make(map[string]bool, len(attachments))
+
+	addMedia := func(source, mimeType, title, description string) {
+		source = getMediaSource(source, post.Link)
+		if source == "" || sources[source] {
+			return
+		}
+		sources[source] = true
+
+		switch {
+		case strings.HasPrefix(mimeType, "audio/"):
+			lesson.Audio = append(lesson.Audio, Media{
+				SiteData: &SiteData{
+					Title:       title,
+					Description: description,
+				},
+				Source: source,
+			})
+		case mimeType == "application/pdf":
+			lesson.Pdf = append(lesson.Pdf, Document{
+				URL:   source,
+				Title: title,
+				Page:  post.Link,
+			})
+		case strings.HasPrefix(mimeType, "video/") || getVideoKind(source) != VideoFile:
+			lesson.Video = append(lesson.Video, Media{
+				SiteData: &SiteData{
+					Title:       title,
+					Description: description,
+				},
+				Source: source,
+				Kind:   getVideoKind(source),
+			})
+		}
+	}
+
+	for _, item := range append(attachments, post.Embedded.FeaturedMedia...) {
+		addMedia(item.SourceURL, item.MimeType, html.UnescapeString(item.Title.Rendered), getTextFromHTML(item.Caption.Rendered))
+	}
+
+	// Media which is embedded in the content of the post.
+	content, err := goquery.NewDocumentFromReader(strings.NewReader(post.Content.Rendered))
+	if err != nil {
+		return lesson
+	}
+
+	content.Find("audio[src], audio source[src], video[src], video source[src], iframe[src], a[href]").Each(func(_ int, s *goquery.Selection) {
+		source, exists := s.Attr("src")
+		if !exists {
+			source, _ = s.Attr("href")
+		}
+
+		mimeType := getMimeTypeFromURL(source)
+
+		// A link in the text of the post, like to a video on YouTube, isn't the
+		// post's media, unless it's to a file of audio or a PDF.
+		if goquery.NodeName(s) == "a" && !strings.HasPrefix(mimeType, "audio/") && mimeType != "application/pdf" {
+			return
+		}
+
+		// The text of a link to a PDF says what it is, like "Source sheet".
+		title := ""
+		if mimeType == "application/pdf" {
+			title = strings.TrimSpace(s.Text())
+		}
+
+		addMedia(source, mimeType, title, "")
+	})
+
+	return lesson
+}
+
+// getAll requests every page of the given endpoint, and decodes all the items into result,
+// which must be a pointer to a slice.
+func (scraper *WordPressScraper) getAll(endpoint string, query url.Values, result interface{}) error {
+	perPage := scraper.PerPage
+	if perPage == 0 {
+		perPage = 100
+	}
+
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("per_page", strconv.Itoa(perPage))
+
+	client := clientFor(scraper.Transport)
+	all := make([]json.RawMessage, 0, perPage)
+
+	for page, totalPages := 1, 1; page <= totalPages; page++ {
+		query.Set("page", strconv.Itoa(page))
+		requestURL := strings.TrimSuffix(scraper.BaseURL, "/") + "/wp-json/wp/v2/" + endpoint + "?" + query.Encode()
+
+		response, err := client.Get(requestURL)
+		if err != nil {
+			return err
+		}
+
+		if response.StatusCode != http.StatusOK {
+			response.Body.Close()
+			return fmt.Errorf("%s: %s", requestURL, response.Status)
+		}
+
+		if total, err := strconv.Atoi(response.Header.Get("X-WP-TotalPages")); err == nil {
+			totalPages = total
+		}
+
+		var items []json.RawMessage
+		err = json.NewDecoder(response.Body).Decode(&items)
+		response.Body.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %s", requestURL, err.Error())
+		}
+
+		all = append(all, items...)
+	}
+
+	// Decode all the items together, into the requested type.
+	allJSON, err := json.Marshal(all)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(allJSON, result)
+}
+
+// Gets the text of some HTML.
+func getTextFromHTML(htmlText string) string {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(htmlText))
+	if err != nil {
+		return strings.TrimSpace(htmlText)
+	}
+
+	return strings.TrimSpace(doc.Text())
+}
+
+// getSanitizedHTML keeps only the formatting and links of the HTML.
+func getSanitizedHTML(htmlText, pageURL string) string {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(htmlText))
+	if err != nil {
+		return ""
+	}
+
+	return sanitizeHTML(doc.Find("body"), pageURL)
+}
+
+// getMediaSource resolves the URL of the media from the URL of the post, and
+// removes the query which WordPress adds to tell players of the same file apart,
+// so that the same file is always the same source.
+func getMediaSource(source, postURL string) string {
+	source = strings.TrimSpace(source)
+	parsed, err := url.Parse(source)
+	if err != nil || source == "" {
+		return source
+	}
+
+	if base, err := url.Parse(postURL); err == nil && postURL != "" {
+		parsed = base.ResolveReference(parsed)
+	}
+
+	if query := parsed.Query(); query.Get("_") != "" {
+		query.Del("_")
+		parsed.RawQuery = query.Encode()
+	}
+
+	return parsed.String()
+}
+
+// Guesses the type of media from its URL.
+func getMimeTypeFromURL(source string) string {
+	path := strings.ToLower(source)
+	if parsed, err := url.Parse(source); err == nil {
+		path = strings.ToLower(parsed.Path)
+	}
+
+	switch {
+	case strings.HasSuffix(path, ".mp3"):
+		return "audio/mpeg"
+	case strings.HasSuffix(path, ".m4a"):
+		return "audio/mp4"
+	case strings.HasSuffix(path, ".pdf"):
+		return "application/pdf"
+	case strings.HasSuffix(path, ".mp4"), strings.HasSuffix(path, ".m4v"):
+		return "video/mp4"
+	}
+
+	return ""
+}
@@ -0,0 +1,168 @@
+package insidescraper
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// newFakeWordPress serves a tiny WordPress REST API, with a fixed number of
+// items on each page so that pagination is used.
+func newFakeWordPress(t *testing.T) *httptest.Server {
+	const perPage = 2
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		site := server.URL
+
+		data := map[string][]interface{}{
+			"/wp-json/wp/v2/categories": {
+				map[string]interface{}{"id": 1, "name": "Chassidus &amp; Kabbalah", "link": site + "/category/chassidus", "parent": 0},
+				map[string]interface{}{"id": 2, "name": "Tanya", "description": "Classes on Tanya", "link": site + "/category/chassidus/tanya", "parent": 1},
+				map[string]interface{}{"id": 3, "name": "Parsha", "link": site + "/category/parsha", "parent": 0},
+			},
+			"/wp-json/wp/v2/posts": {
+				map[string]interface{}{
+					"id": 10, "link": site + "/tanya-1", "categories": []int{2},
+					"title":   map[string]string{"rendered": "Tanya Chapter 1"},
+					"excerpt": map[string]string{"rendered": "<p>The first chapter</p>"},
+					"content": map[string]string{"rendered": `<audio src="` + site + `/tanya-1.mp3"></audio>`},
+				},
+				map[string]interface{}{
+					"id": 11, "link": site + "/bereishis", "categories": []int{3},
+					"title":   map[string]string{"rendered": "Bereishis"},
+					"content": map[string]string{"rendered": `<a href="` + site + `/bereishis.pdf">Source sheet</a><iframe src="https://www.youtube.com/embed/xyz"></iframe>`},
+				},
+			},
+			"/wp-json/wp/v2/media": {
+				map[string]interface{}{
+					"id": 20, "post": 11, "mime_type": "audio/mpeg", "source_url": site + "/bereishis-1.mp3",
+					"title": map[string]string{"rendered": "Class One"},
+				},
+				map[string]interface{}{
+					"id": 21, "post": 11, "mime_type": "audio/mpeg", "source_url": site + "/bereishis-2.mp3",
+					"title": map[string]string{"rendered": "Class Two"},
+				},
+				map[string]interface{}{"id": 22, "post": 0, "mime_type": "image/jpeg", "source_url": site + "/logo.jpg"},
+			},
+		}
+
+		items, exists := data[r.URL.Path]
+		if !exists {
+			http.NotFound(w, r)
+			return
+		}
+
+		if r.URL.Query().Get("per_page") != strconv.Itoa(perPage) {
+			t.Errorf("Expected %d items per page, got %s", perPage, r.URL.Query().Get("per_page"))
+		}
+
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		start, end := (page-1)*perPage, page*perPage
+		if end > len(items) {
+			end = len(items)
+		}
+
+		w.Header().Set("X-WP-TotalPages", strconv.Itoa((len(items)+perPage-1)/perPage))
+		json.NewEncoder(w).Encode(items[start:end])
+	}))
+
+	return server
+}
+
+func TestWordPressScraper(t *testing.T) {
+	server := newFakeWordPress(t)
+	defer server.Close()
+
+	scraper := WordPressScraper{
+		BaseURL: server.URL,
+		PerPage: 2,
+	}
+	if err := scraper.Scrape(); err != nil {
+		t.Fatal(err)
+	}
+
+	site := scraper.Site
+
+	if len(site.TopLevel) != 2 || len(site.Sections) != 3 || len(site.Lessons) != 2 {
+		t.Fatalf("Expected 2 top level sections, 3 sections and 2 lessons, got %+v", site)
+	}
+
+	chassidus := site.Sections[server.URL+"/category/chassidus"]
+	if chassidus.Title != "Chassidus & Kabbalah" || len(chassidus.Sections) != 1 {
+		t.Errorf("Expected the Chassidus section to contain Tanya, got %+v", chassidus)
+	}
+
+	tanya := site.Lessons[server.URL+"/tanya-1"]
+	if len(tanya.Audio) != 1 || tanya.Description != "The first chapter" {
+		t.Errorf("Expected the Tanya lesson to have embedded audio, got %+v", tanya)
+	}
+
+	bereishis := site.Lessons[server.URL+"/bereishis"]
+	if len(bereishis.Audio) != 2 || len(bereishis.Pdf) != 1 || !strings.HasSuffix(bereishis.Pdf[0].URL, ".pdf") || bereishis.Pdf[0].Title != "Source sheet" {
+		t.Errorf("Expected the Bereishis lesson to have 2 audio attachments and a PDF, got %+v", bereishis)
+	}
+	if len(bereishis.Video) != 1 || bereishis.Video[0].Kind != YouTubeVideo {
+		t.Errorf("Expected the Bereishis lesson to have an embedded YouTube video, got %+v", bereishis.Video)
+	}
+
+	// The rest of the pipeline works on the site as it is.
+	counter := MakeCounter(&site)
+	counter.CountLessons()
+
+	if count := site.Sections[server.URL+"/category/chassidus"].AudioCount; count != 1 {
+		t.Errorf("Expected Chassidus to have 1 audio class, got %d", count)
+	}
+
+	resolver := SectionResolver{Site: site}
+	resolver.ResolveSite()
+
+	if len(resolver.ResolvedSite.TopLevel) != 2 {
+		t.Errorf("Expected the resolved site to have 2 top level sections, got %d", len(resolver.ResolvedSite.TopLevel))
+	}
+}
+
+func TestWordPressShortcodeMedia(t *testing.T) {
+	const site = "https://example.com"
+
+	// The markup which WordPress renders for [audio] and [video] shortcodes,
+	// with a link to the file for browsers which can't play it.
+	post := wpPost{
+		Link:  site + "/2020/01/tanya-2/",
+		Title: wpRendered{Rendered: "Tanya Chapter 2"},
+		Content: wpRendered{Rendered: `<p>See also <a href="https://www.youtube.com/watch?v=xyz">this video</a>.</p>
+<!--[if lt IE 9]><script>document.createElement('audio');</script><![endif]-->
+<audio class="wp-audio-shortcode" id="audio-12-1" preload="none" style="width: 100%;" controls="controls"><source type="audio/mpeg" src="` + site + `/wp-content/uploads/2020/01/tanya-2.mp3?_=1" /><a href="` + site + `/wp-content/uploads/2020/01/tanya-2.mp3">` + site + `/wp-content/uploads/2020/01/tanya-2.mp3</a></audio>
+<audio class="wp-audio-shortcode" id="audio-12-2" preload="none" style="width: 100%;" controls="controls"><source type="audio/mpeg" src="/wp-content/uploads/2020/01/tanya-2b.mp3?_=2" /><a href="/wp-content/uploads/2020/01/tanya-2b.mp3">/wp-content/uploads/2020/01/tanya-2b.mp3</a></audio>
+<div style="width: 640px;" class="wp-video"><video class="wp-video-shortcode" id="video-12-1" width="640" height="360" preload="metadata" controls="controls"><source type="video/mp4" src="` + site + `/wp-content/uploads/2020/01/tanya-2.mp4?_=1" /><a href="` + site + `/wp-content/uploads/2020/01/tanya-2.mp4">` + site + `/wp-content/uploads/2020/01/tanya-2.mp4</a></video></div>`},
+	}
+	attachments := []wpMedia{
+		{MimeType: "audio/mpeg", SourceURL: site + "/wp-content/uploads/2020/01/tanya-2.mp3", Title: wpRendered{Rendered: "Chapter 2"}},
+	}
+
+	lesson := getLessonFromPost(post, attachments)
+
+	expectedAudio := []string{
+		site + "/wp-content/uploads/2020/01/tanya-2.mp3",
+		site + "/wp-content/uploads/2020/01/tanya-2b.mp3",
+	}
+	audio := make([]string, 0, len(lesson.Audio))
+	for _, media := range lesson.Audio {
+		audio = append(audio, media.Source)
+	}
+	if !reflect.DeepEqual(audio, expectedAudio) {
+		t.Errorf("Expected audio %v, got %v", expectedAudio, audio)
+	}
+	if lesson.Audio[0].Title != "Chapter 2" {
+		t.Errorf("Expected the attached audio to keep its title, got %q", lesson.Audio[0].Title)
+	}
+
+	// The YouTube link in the text isn't a video of the lesson.
+	if len(lesson.Video) != 1 || lesson.Video[0].Source != site+"/wp-content/uploads/2020/01/tanya-2.mp4" {
+		t.Errorf("Expected only the embedded video, got %+v", lesson.Video)
+	}
+}